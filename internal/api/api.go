@@ -0,0 +1,104 @@
+package api
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// Prefix is the path prefix of version 1 of the api
+	Prefix = "/api/v1"
+
+	// StatusOK is the status of a running server
+	StatusOK = "OK"
+
+	// StatusShutdown is the status that shuts the server down
+	StatusShutdown = "shutdown"
+)
+
+// OpenAPI is the OpenAPI 3 document of version 1 of the api
+//
+//go:embed openapi.json
+var OpenAPI []byte
+
+// Status is the status of the server
+type Status struct {
+	Status      string
+	QueuedRuns  int
+	RunningRuns int
+}
+
+const (
+	// error codes of error responses
+	CodeBadRequest           = "bad-request"
+	CodeInvalidEvent         = "invalid-event"
+	CodeUnauthorized         = "unauthorized"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not-found"
+	CodeMethodNotAllowed     = "method-not-allowed"
+	CodeConflict             = "conflict"
+	CodeTooLarge             = "too-large"
+	CodeUnsupportedMediaType = "unsupported-media-type"
+	CodeInternal             = "internal-error"
+)
+
+// Error is the json body of an error response of the server
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+
+	// Field is the invalid field of an event if present
+	Field string `json:",omitempty"`
+}
+
+// Error returns the error as string
+func (e *Error) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("%d %s: %s (field %s)", e.Status, e.Code,
+			e.Message, e.Field)
+	}
+	return fmt.Sprintf("%d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// Write writes the error as response to w
+func (e *Error) Write(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(e.Status)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(e)
+}
+
+// NewError returns a new error with status, code and a formatted message
+func NewError(status int, code, format string, a ...any) *Error {
+	return &Error{
+		Status:  status,
+		Code:    code,
+		Message: fmt.Sprintf(format, a...),
+	}
+}
+
+// FromResponse returns the error in the response resp or nil if resp is not
+// an error response; responses without json error body are converted to
+// errors with the status text as code
+func FromResponse(resp *http.Response) *Error {
+	if resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(resp.Body)
+	e := &Error{}
+	if err := json.Unmarshal(body, e); err != nil || e.Code == "" {
+		e = &Error{
+			Code:    http.StatusText(resp.StatusCode),
+			Message: strings.TrimSpace(string(body)),
+		}
+	}
+	e.Status = resp.StatusCode
+	return e
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// TestError tests writing errors and reading them from responses
+func TestError(t *testing.T) {
+	// json error response
+	want := NewError(http.StatusBadRequest, CodeInvalidEvent,
+		"WaitMax < WaitMin")
+	want.Field = "WaitMax"
+	rec := httptest.NewRecorder()
+	want.Write(rec)
+	resp := rec.Result()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("got content type %s, want application/json", ct)
+	}
+	got := FromResponse(resp)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+	s := "400 invalid-event: WaitMax < WaitMin (field WaitMax)"
+	if got.Error() != s {
+		t.Errorf("got %q, want %q", got.Error(), s)
+	}
+
+	// plain text error response
+	rec = httptest.NewRecorder()
+	http.NotFound(rec, nil)
+	got = FromResponse(rec.Result())
+	want = &Error{
+		Status:  http.StatusNotFound,
+		Code:    "Not Found",
+		Message: "404 page not found",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+
+	// no error response
+	rec = httptest.NewRecorder()
+	rec.WriteHeader(http.StatusCreated)
+	if got := FromResponse(rec.Result()); got != nil {
+		t.Errorf("got %v, want nil", got)
+	}
+}
+
+// TestOpenAPI tests the OpenAPI document
+func TestOpenAPI(t *testing.T) {
+	doc := struct {
+		OpenAPI string
+		Servers []struct{ URL string }
+		Paths   map[string]map[string]any
+	}{}
+	if err := json.Unmarshal(OpenAPI, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("got version %s, want 3.0.3", doc.OpenAPI)
+	}
+	if len(doc.Servers) != 1 || doc.Servers[0].URL != Prefix {
+		t.Errorf("got servers %v, want %s", doc.Servers, Prefix)
+	}
+	for path, methods := range map[string][]string{
+		"/commands":             {"get"},
+		"/commands/{name}":      {"get"},
+		"/events":               {"get", "post", "delete"},
+		"/events/{name}":        {"get", "put", "patch", "delete"},
+		"/events/{name}/runs":   {"get"},
+		"/events/{name}/output": {"get"},
+		"/events/{name}/pause":  {"post"},
+		"/events/{name}/resume": {"post"},
+		"/status":               {"get", "put"},
+		"/openapi.json":         {"get"},
+	} {
+		for _, m := range methods {
+			if doc.Paths[path][m] == nil {
+				t.Errorf("%s %s not in document", m, path)
+			}
+		}
+	}
+}
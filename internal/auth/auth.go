@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/hwipl/schedule-events/internal/api"
+)
+
+const (
+	// RoleReadOnly allows reading commands, events and the status
+	RoleReadOnly = "read-only"
+
+	// RoleSchedule allows reading and scheduling, updating and deleting
+	// events with the allowed commands
+	RoleSchedule = "schedule"
+
+	// RoleAdmin allows everything including stopping and shutting down
+	// the server
+	RoleAdmin = "admin"
+)
+
+var (
+	// tokensMutex protects tokens
+	tokensMutex sync.Mutex
+
+	// tokens stores all api tokens, nil disables authentication
+	tokens []*Token
+)
+
+// Token is an api token with its role and allowed commands
+type Token struct {
+	Token string
+	Role  string
+
+	// Commands are the commands that events scheduled with the token may
+	// use; empty means all commands
+	Commands []string
+}
+
+// check checks if the token is valid
+func (t *Token) check() error {
+	if t.Token == "" {
+		return errors.New("empty token")
+	}
+	switch t.Role {
+	case RoleReadOnly, RoleSchedule, RoleAdmin:
+	default:
+		return fmt.Errorf("invalid role: %s", t.Role)
+	}
+	return nil
+}
+
+// Allowed returns whether the token allows a request with method on the api
+// path
+func (t *Token) Allowed(method, path string) bool {
+	switch {
+	case t.Role == RoleAdmin:
+		return true
+	case method == http.MethodGet:
+		return true
+	case t.Role == RoleSchedule:
+		return strings.HasPrefix(path, "/events/") ||
+			strings.HasPrefix(path, api.Prefix+"/events/") ||
+			path == api.Prefix+"/events" &&
+				method == http.MethodPost
+	default:
+		return false
+	}
+}
+
+// AllCommandsAllowed returns whether events scheduled with the token may use
+// all commands
+func (t *Token) AllCommandsAllowed() bool {
+	return t.Role == RoleAdmin || len(t.Commands) == 0
+}
+
+// CommandAllowed returns whether events scheduled with the token may use the
+// command with name
+func (t *Token) CommandAllowed(name string) bool {
+	return t.AllCommandsAllowed() || slices.Contains(t.Commands, name)
+}
+
+// Enabled returns whether authentication is enabled
+func Enabled() bool {
+	tokensMutex.Lock()
+	defer tokensMutex.Unlock()
+
+	return tokens != nil
+}
+
+// Get returns the token matching the api token s
+func Get(s string) *Token {
+	tokensMutex.Lock()
+	defer tokensMutex.Unlock()
+
+	var found *Token
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(t.Token), []byte(s)) == 1 {
+			found = t
+		}
+	}
+	return found
+}
+
+// FromRequest returns the token in the bearer authorization header of the
+// http request r
+func FromRequest(r *http.Request) *Token {
+	s, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || s == "" {
+		return nil
+	}
+	return Get(s)
+}
+
+// tokenKey is the context key of a token
+type tokenKey struct{}
+
+// NewContext returns a copy of ctx that carries token t
+func NewContext(ctx context.Context, t *Token) context.Context {
+	return context.WithValue(ctx, tokenKey{}, t)
+}
+
+// FromContext returns the token in ctx or nil
+func FromContext(ctx context.Context) *Token {
+	t, _ := ctx.Value(tokenKey{}).(*Token)
+	return t
+}
+
+// TokensFromJSON loads the api tokens from the json file in path and enables
+// authentication
+func TokensFromJSON(path string) error {
+	// read file
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	// parse tokens
+	toks := []*Token{}
+	if err := json.Unmarshal(file, &toks); err != nil {
+		return err
+	}
+
+	// check tokens
+	for _, t := range toks {
+		if err := t.check(); err != nil {
+			return err
+		}
+	}
+
+	// set tokens
+	tokensMutex.Lock()
+	defer tokensMutex.Unlock()
+
+	tokens = toks
+	return nil
+}
@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestTokenAllowed tests the permissions of token roles
+func TestTokenAllowed(t *testing.T) {
+	readOnly := &Token{Token: "r", Role: RoleReadOnly}
+	schedule := &Token{Token: "s", Role: RoleSchedule}
+	admin := &Token{Token: "a", Role: RoleAdmin}
+
+	for _, test := range []struct {
+		token  *Token
+		method string
+		path   string
+		want   bool
+	}{
+		{readOnly, http.MethodGet, "/events/", true},
+		{readOnly, http.MethodGet, "/status", true},
+		{readOnly, http.MethodPost, "/events/", false},
+		{readOnly, http.MethodDelete, "/events/e", false},
+		{schedule, http.MethodGet, "/commands/", true},
+		{schedule, http.MethodPost, "/events/", true},
+		{schedule, http.MethodPatch, "/events/e", true},
+		{schedule, http.MethodPut, "/status", false},
+		{schedule, http.MethodPost, "/stop", false},
+		{schedule, http.MethodPost, "/api/v1/events", true},
+		{schedule, http.MethodPut, "/api/v1/events/e", true},
+		{schedule, http.MethodDelete, "/api/v1/events", false},
+		{schedule, http.MethodPut, "/api/v1/status", false},
+		{readOnly, http.MethodGet, "/api/v1/openapi.json", true},
+		{readOnly, http.MethodPost, "/api/v1/events/e/pause", false},
+		{admin, http.MethodPut, "/status", true},
+		{admin, http.MethodDelete, "/api/v1/events", true},
+		{admin, http.MethodPost, "/events/", true},
+	} {
+		got := test.token.Allowed(test.method, test.path)
+		if got != test.want {
+			t.Errorf("%s %s %s: got %t, want %t", test.token.Role,
+				test.method, test.path, got, test.want)
+		}
+	}
+}
+
+// TestTokenCommandAllowed tests the allowed commands of tokens
+func TestTokenCommandAllowed(t *testing.T) {
+	all := &Token{Token: "s1", Role: RoleSchedule}
+	some := &Token{Token: "s2", Role: RoleSchedule,
+		Commands: []string{"ls"}}
+	admin := &Token{Token: "a", Role: RoleAdmin, Commands: []string{"ls"}}
+
+	if !all.CommandAllowed("date") {
+		t.Error("command not allowed without command list")
+	}
+	if !some.CommandAllowed("ls") || some.CommandAllowed("date") {
+		t.Error("invalid allowed commands with command list")
+	}
+	if !admin.CommandAllowed("date") {
+		t.Error("command not allowed for admin")
+	}
+	if !all.AllCommandsAllowed() || some.AllCommandsAllowed() ||
+		!admin.AllCommandsAllowed() {
+		t.Error("invalid all commands allowed")
+	}
+}
+
+// TestTokensFromJSON tests loading tokens and getting them from requests
+func TestTokensFromJSON(t *testing.T) {
+	defer func() { tokens = nil }()
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		err := os.WriteFile(path, []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// invalid tokens
+	for _, content := range []string{
+		`not json`,
+		`[{"Token":"","Role":"admin"}]`,
+		`[{"Token":"t","Role":"invalid"}]`,
+	} {
+		path := write("invalid.json", content)
+		if err := TokensFromJSON(path); err == nil {
+			t.Errorf("%s: got nil, want error", content)
+		}
+	}
+	if Enabled() {
+		t.Error("authentication enabled by invalid tokens")
+	}
+	if err := TokensFromJSON(filepath.Join(dir, "missing")); err == nil {
+		t.Error("missing file: got nil, want error")
+	}
+
+	// valid tokens
+	path := write("tokens.json", `[
+		{"Token":"admin-token","Role":"admin"},
+		{"Token":"read-token","Role":"read-only"}
+	]`)
+	if err := TokensFromJSON(path); err != nil {
+		t.Fatal(err)
+	}
+	if !Enabled() {
+		t.Error("authentication not enabled")
+	}
+
+	// tokens from requests
+	for _, test := range []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"Bearer ", ""},
+		{"Bearer unknown", ""},
+		{"Basic admin-token", ""},
+		{"Bearer admin-token", RoleAdmin},
+		{"Bearer read-token", RoleReadOnly},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/status", nil)
+		if test.header != "" {
+			r.Header.Set("Authorization", test.header)
+		}
+		got := ""
+		if tok := FromRequest(r); tok != nil {
+			got = tok.Role
+		}
+		if got != test.want {
+			t.Errorf("%q: got %q, want %q", test.header, got,
+				test.want)
+		}
+	}
+
+	// tokens in contexts
+	tok := Get("admin-token")
+	ctx := NewContext(context.Background(), tok)
+	if got := FromContext(ctx); got != tok {
+		t.Errorf("got %v, want %v", got, tok)
+	}
+	if FromContext(context.Background()) != nil {
+		t.Error("got token from empty context")
+	}
+}
@@ -2,17 +2,135 @@ package client
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/hwipl/schedule-events/internal/api"
 	"github.com/hwipl/schedule-events/internal/command"
 	"github.com/hwipl/schedule-events/internal/event"
 )
 
+var (
+	// PreviewRuns is the number of run times shown by the preview-events
+	// operation
+	PreviewRuns = 10
+
+	// Token is the api token sent to the server, empty disables sending
+	// a token
+	Token = ""
+
+	// TLS enables tls without ca certificates or client certificates
+	TLS = false
+
+	// CAFile is the file with the ca certificates for verifying the
+	// server certificate, empty uses the system ca certificates
+	CAFile = ""
+
+	// CertFile and KeyFile are the files with the tls client certificate
+	// and key, empty disables client certificates
+	CertFile = ""
+	KeyFile  = ""
+)
+
+// useTLS returns whether the client connects to the server with tls
+func useTLS() bool {
+	return TLS || CAFile != "" || CertFile != ""
+}
+
+// socketPath returns the path of the unix domain socket in addr and whether
+// addr is a unix domain socket address
+func socketPath(addr string) (string, bool) {
+	return strings.CutPrefix(addr, "unix:")
+}
+
+// baseURL returns the base url of api v1 of the server with addr; unix
+// domain socket addresses use the placeholder host "unix"
+func baseURL(addr string) string {
+	if _, ok := socketPath(addr); ok {
+		addr = "unix"
+	}
+	if useTLS() {
+		return "https://" + addr + api.Prefix
+	}
+	return "http://" + addr + api.Prefix
+}
+
+// tlsConfig returns the tls configuration of the client
+func tlsConfig() (*tls.Config, error) {
+	c := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	// load ca certificates
+	if CAFile != "" {
+		pem, err := os.ReadFile(CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no ca certificates in %s",
+				CAFile)
+		}
+		c.RootCAs = pool
+	}
+
+	// load client certificate
+	if CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(CertFile, KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+	return c, nil
+}
+
+// transport returns the http transport of the client connecting to addr
+func transport(addr string) (http.RoundTripper, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if path, ok := socketPath(addr); ok {
+		t.DialContext = func(ctx context.Context, _, _ string) (
+			net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		}
+	}
+	if useTLS() {
+		c, err := tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		t.TLSClientConfig = c
+	}
+	if Token != "" {
+		return &tokenTransport{token: Token, base: t}, nil
+	}
+	return t, nil
+}
+
+// tokenTransport is a http transport that adds the api token to requests
+type tokenTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+// RoundTrip adds the api token to request r and sends it
+func (t *tokenTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+t.token)
+	return t.base.RoundTrip(r)
+}
+
 // get retrieves content from url
 func get(url string) []byte {
 	resp, err := http.Get(url)
@@ -20,20 +138,20 @@ func get(url string) []byte {
 		log.Fatal(err)
 	}
 	defer resp.Body.Close()
+	if err := api.FromResponse(resp); err != nil {
+		log.Fatal(err)
+	}
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		log.Fatal(err)
 	}
-	if resp.StatusCode > 299 {
-		log.Fatal(resp.StatusCode)
-	}
 	return body
 }
 
 // getCommandsAll retrieves all commands from the server and prints them
 func getCommandsAll(addr string) {
 	// get commands from server
-	url := fmt.Sprintf("http://%s/commands", addr)
+	url := fmt.Sprintf("%s/commands", baseURL(addr))
 	body := get(url)
 
 	// make sure it's a valid json Command array
@@ -51,7 +169,7 @@ func getCommandsAll(addr string) {
 // getCommandsOne retrieves the command with name from the server and prints it
 func getCommandsOne(addr, name string) {
 	// get command from server
-	url := fmt.Sprintf("http://%s/commands/%s", addr, name)
+	url := fmt.Sprintf("%s/commands/%s", baseURL(addr), name)
 	body := get(url)
 
 	// make sure it's a valid json Command
@@ -83,7 +201,7 @@ func getCommands(addr string) {
 // getEventsAll retrieves all events from the server and prints them
 func getEventsAll(addr string) {
 	// get events from server
-	url := fmt.Sprintf("http://%s/events", addr)
+	url := fmt.Sprintf("%s/events", baseURL(addr))
 	body := get(url)
 
 	// make sure it's a valid json Event array
@@ -101,7 +219,7 @@ func getEventsAll(addr string) {
 // getEventsOne retrieves the event with name from the server and prints it
 func getEventsOne(addr, name string) {
 	// get event from server
-	url := fmt.Sprintf("http://%s/events/%s", addr, name)
+	url := fmt.Sprintf("%s/events/%s", baseURL(addr), name)
 	body := get(url)
 
 	// make sure it's a valid json Event
@@ -130,26 +248,89 @@ func getEvents(addr string) {
 	}
 }
 
+// getRunsOne retrieves the run history of the event with name from the
+// server and prints it
+func getRunsOne(addr, name string) {
+	// get runs from server
+	url := fmt.Sprintf("%s/events/%s/runs", baseURL(addr), name)
+	body := get(url)
+
+	// make sure it's a valid json Run array
+	runs := []*event.Run{}
+	if err := json.Unmarshal(body, &runs); err != nil {
+		log.Fatal(err)
+	}
+
+	// print as indented json
+	var out bytes.Buffer
+	json.Indent(&out, body, "", "    ")
+	fmt.Println(&out)
+}
+
+// getRuns retrieves the run history of all events in the event list from the
+// server and prints it
+func getRuns(addr string) {
+	for _, evt := range event.List() {
+		log.Println("Getting event runs from server:", evt.Name)
+		getRunsOne(addr, evt.Name)
+	}
+}
+
+// getOutputOne retrieves the output of the last command run of the event
+// with name from the server and prints it
+func getOutputOne(addr, name string) {
+	// get output from server
+	url := fmt.Sprintf("%s/events/%s/output", baseURL(addr), name)
+	body := get(url)
+
+	// make sure it's a valid json Result
+	result := command.Result{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		log.Fatal(err)
+	}
+
+	// print as indented json
+	var out bytes.Buffer
+	json.Indent(&out, body, "", "    ")
+	fmt.Println(&out)
+}
+
+// getOutput retrieves the output of the last command run of all events in
+// the event list from the server and prints it
+func getOutput(addr string) {
+	for _, evt := range event.List() {
+		log.Println("Getting event output from server:", evt.Name)
+		getOutputOne(addr, evt.Name)
+	}
+}
+
 // getStatus retrieves the status from the server and prints it
 func getStatus(addr string) {
 	log.Println("Getting status from server")
 
-	url := fmt.Sprintf("http://%s/status", addr)
+	url := fmt.Sprintf("%s/status", baseURL(addr))
 	body := get(url)
-	fmt.Println(string(body))
+
+	// make sure it's a valid json Status
+	status := &api.Status{}
+	if err := json.Unmarshal(body, status); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Status: %s\nQueued runs: %d\nRunning runs: %d\n",
+		status.Status, status.QueuedRuns, status.RunningRuns)
 }
 
-// handleResponse a response discarding the body and checking the status code
+// handleResponse a response discarding the body and checking the status
+// code; error responses are printed
 func handleResponse(resp *http.Response) {
 	defer resp.Body.Close()
+	if err := api.FromResponse(resp); err != nil {
+		log.Fatal(err)
+	}
 	_, err := io.Copy(ioutil.Discard, resp.Body)
 	if err != nil {
 		log.Fatal(err)
 	}
-	if resp.StatusCode > 299 {
-		log.Fatal(resp.StatusCode)
-	}
-
 }
 
 // setEvents sends the client's event list to the server for scheduling
@@ -157,7 +338,7 @@ func setEvents(addr string) {
 	log.Println("Sending events to server")
 
 	// send events to server
-	url := fmt.Sprintf("http://%s/events/", addr)
+	url := fmt.Sprintf("%s/events", baseURL(addr))
 	for _, e := range event.List() {
 		log.Println("Sending event:", e.Name)
 
@@ -174,26 +355,95 @@ func setEvents(addr string) {
 	}
 }
 
-// setStatus sends status request to server
-func setStatus(addr, status string) {
-	url := fmt.Sprintf("http://%s/status/%s", addr, status)
-	resp, err := http.Post(url, "", nil)
-	if err != nil {
-		log.Fatal(err)
+// updateEvents sends the client's event list to the server for replacing the
+// existing events with the same names
+func updateEvents(addr string) {
+	log.Println("Updating events on server")
+
+	for _, e := range event.List() {
+		log.Println("Updating event:", e.Name)
+
+		b, err := e.JSON()
+		if err != nil {
+			log.Fatal(err)
+		}
+		url := fmt.Sprintf("%s/events/%s", baseURL(addr), e.Name)
+		req, err := http.NewRequest(http.MethodPut, url,
+			bytes.NewReader(b))
+		if err != nil {
+			log.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			log.Fatal(err)
+		}
+		handleResponse(resp)
 	}
-	handleResponse(resp)
 }
 
 // shutdown sends a shutdown request to the server
 func shutdown(addr string) {
 	log.Println("Sending shutdown to server")
-	setStatus(addr, "shutdown")
+
+	b, err := json.Marshal(&api.Status{Status: api.StatusShutdown})
+	if err != nil {
+		log.Fatal(err)
+	}
+	url := fmt.Sprintf("%s/status", baseURL(addr))
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(b))
+	if err != nil {
+		log.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Fatal(err)
+	}
+	handleResponse(resp)
 }
 
 // stop sends a stop request to the server
 func stop(addr string) {
 	log.Println("Sending stop to server")
-	setStatus(addr, "stop")
+
+	url := fmt.Sprintf("%s/events", baseURL(addr))
+	req, err := http.NewRequest(http.MethodDelete, url, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Fatal(err)
+	}
+	handleResponse(resp)
+}
+
+// postEvents sends action requests for the client's event list to the server
+func postEvents(addr, action string) {
+	for _, e := range event.List() {
+		log.Printf("Sending %s for event: %s", action, e.Name)
+
+		url := fmt.Sprintf("%s/events/%s/%s", baseURL(addr), e.Name,
+			action)
+		resp, err := http.Post(url, "", nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		handleResponse(resp)
+	}
+}
+
+// pauseEvents pauses events on the server
+func pauseEvents(addr string) {
+	log.Println("Pausing events on server")
+	postEvents(addr, "pause")
+}
+
+// resumeEvents resumes events on the server
+func resumeEvents(addr string) {
+	log.Println("Resuming events on server")
+	postEvents(addr, "resume")
 }
 
 // delEvents deletes events on the server
@@ -203,7 +453,7 @@ func delEvents(addr string) {
 	for _, e := range event.List() {
 		log.Println("Deleting event:", e.Name)
 
-		url := fmt.Sprintf("http://%s/events/%s", addr, e.Name)
+		url := fmt.Sprintf("%s/events/%s", baseURL(addr), e.Name)
 		req, err := http.NewRequest(http.MethodDelete, url, nil)
 		if err != nil {
 			log.Fatal(err)
@@ -216,9 +466,45 @@ func delEvents(addr string) {
 	}
 }
 
+// previewEvents checks the client's event list and prints the next run times
+// of the events without connecting to the server
+func previewEvents() {
+	log.Println("Previewing events")
+
+	for _, e := range event.List() {
+		log.Println("Previewing event:", e.Name)
+
+		if err := e.Check(); err != nil {
+			log.Fatalf("Event %s: %s", e.Name, err)
+		}
+		times, err := e.Preview(time.Now(), PreviewRuns)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// print as indented json
+		b, err := json.MarshalIndent(times, "", "    ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(b))
+	}
+}
+
 // Run starts the client connecting to addr and executing op
 func Run(addr, op string) {
+	if op == "preview-events" {
+		// works offline without a server
+		previewEvents()
+		return
+	}
+
 	log.Println("Starting client connecting to:", addr)
+	t, err := transport(addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	http.DefaultClient.Transport = t
 	switch op {
 	case "get-commands":
 		getCommands(addr)
@@ -226,8 +512,18 @@ func Run(addr, op string) {
 		getEvents(addr)
 	case "set-events":
 		setEvents(addr)
+	case "update-events":
+		updateEvents(addr)
 	case "delete-events":
 		delEvents(addr)
+	case "pause-events":
+		pauseEvents(addr)
+	case "resume-events":
+		resumeEvents(addr)
+	case "get-output":
+		getOutput(addr)
+	case "get-runs":
+		getRuns(addr)
 	case "get-status":
 		getStatus(addr)
 	case "shutdown":
@@ -0,0 +1,148 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCertificates writes a ca certificate and a certificate and key signed
+// by it to dir and returns their file names
+func writeCertificates(t *testing.T, dir string) (ca, cert, key string) {
+	t.Helper()
+	newKey := func() *ecdsa.PrivateKey {
+		k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	writePEM := func(name, typ string, b []byte) string {
+		path := filepath.Join(dir, name)
+		data := pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: b})
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// create ca certificate
+	caKey := newKey()
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate,
+		caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// create certificate signed by ca
+	certKey := newKey()
+	certTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "test-cert"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth,
+			x509.ExtKeyUsageClientAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, certTemplate,
+		caTemplate, &certKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(certKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return writePEM("ca.pem", "CERTIFICATE", caDER),
+		writePEM("cert.pem", "CERTIFICATE", certDER),
+		writePEM("key.pem", "PRIVATE KEY", keyDER)
+}
+
+// TestBaseURL tests the base url of the server with and without tls
+func TestBaseURL(t *testing.T) {
+	defer func() { TLS = false }()
+	for _, test := range []struct {
+		tls  bool
+		addr string
+		want string
+	}{
+		{false, "localhost:8080", "http://localhost:8080/api/v1"},
+		{true, "localhost:8080", "https://localhost:8080/api/v1"},
+		{false, "unix:/run/se.sock", "http://unix/api/v1"},
+		{true, "unix:/run/se.sock", "https://unix/api/v1"},
+	} {
+		TLS = test.tls
+		if got := baseURL(test.addr); got != test.want {
+			t.Errorf("%v, %s: got %s, want %s", test.tls, test.addr,
+				got, test.want)
+		}
+	}
+}
+
+// TestTLSConfig tests creating the tls configuration of the client
+func TestTLSConfig(t *testing.T) {
+	defer func() { CAFile, CertFile, KeyFile = "", "", "" }()
+	dir := t.TempDir()
+	ca, cert, key := writeCertificates(t, dir)
+	empty := filepath.Join(dir, "empty.pem")
+	if err := os.WriteFile(empty, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "does-not-exist.pem")
+
+	// test invalid configurations
+	for _, test := range []struct {
+		ca, cert, key string
+	}{
+		{empty, "", ""},
+		{missing, "", ""},
+		{"", cert, missing},
+		{"", missing, key},
+		{"", ca, key},
+	} {
+		CAFile, CertFile, KeyFile = test.ca, test.cert, test.key
+		if _, err := tlsConfig(); err == nil {
+			t.Errorf("%+v: got nil, want error", test)
+		}
+	}
+
+	// test system ca certificates without client certificate
+	CAFile, CertFile, KeyFile = "", "", ""
+	c, err := tlsConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.RootCAs != nil || len(c.Certificates) != 0 {
+		t.Errorf("got %+v, want default configuration", c)
+	}
+
+	// test ca certificates and client certificate
+	CAFile, CertFile, KeyFile = ca, cert, key
+	c, err = tlsConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.RootCAs == nil || len(c.Certificates) != 1 {
+		t.Errorf("got %+v, want ca and client certificates", c)
+	}
+}
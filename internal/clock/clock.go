@@ -0,0 +1,222 @@
+package clock
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock provides the current time and timers
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a timer that sends the current time on its channel after its
+// duration
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// Rand is a source of random numbers
+type Rand interface {
+	// Int63n returns a non-negative random number in [0, n)
+	Int63n(n int64) int64
+}
+
+// realTimer is a Timer backed by a time.Timer
+type realTimer struct {
+	t *time.Timer
+}
+
+// C returns the channel of the timer
+func (r *realTimer) C() <-chan time.Time {
+	return r.t.C
+}
+
+// Stop stops the timer
+func (r *realTimer) Stop() bool {
+	return r.t.Stop()
+}
+
+// Real is the Clock of the system using the time package
+type Real struct{}
+
+// Now returns the current time
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+// NewTimer returns a new timer that fires after duration d
+func (Real) NewTimer(d time.Duration) Timer {
+	return &realTimer{t: time.NewTimer(d)}
+}
+
+// lockedRand is a Rand that is safe for concurrent use
+type lockedRand struct {
+	sync.Mutex
+	r *rand.Rand
+}
+
+// Int63n returns a non-negative random number in [0, n)
+func (l *lockedRand) Int63n(n int64) int64 {
+	l.Lock()
+	defer l.Unlock()
+
+	return l.r.Int63n(n)
+}
+
+// NewRand returns a new Rand seeded with seed that is safe for concurrent
+// use
+func NewRand(seed int64) Rand {
+	return &lockedRand{r: rand.New(rand.NewSource(seed))}
+}
+
+// fakeTimer is a Timer of a Fake clock
+type fakeTimer struct {
+	clock *Fake
+	when  time.Time
+	c     chan time.Time
+}
+
+// C returns the channel of the timer
+func (f *fakeTimer) C() <-chan time.Time {
+	return f.c
+}
+
+// Stop stops the timer; it returns false if the timer already fired or was
+// stopped
+func (f *fakeTimer) Stop() bool {
+	f.clock.mutex.Lock()
+	defer f.clock.mutex.Unlock()
+
+	for i, t := range f.clock.timers {
+		if t == f {
+			f.clock.timers = append(f.clock.timers[:i],
+				f.clock.timers[i+1:]...)
+			f.clock.changed()
+			return true
+		}
+	}
+	return false
+}
+
+// Fake is a Clock whose time only changes when it is advanced manually
+type Fake struct {
+	mutex   sync.Mutex
+	now     time.Time
+	timers  []*fakeTimer
+	waiters chan struct{}
+}
+
+// changed notifies goroutines waiting for timer changes; must be called
+// while holding the mutex
+func (f *Fake) changed() {
+	close(f.waiters)
+	f.waiters = make(chan struct{})
+}
+
+// Now returns the current time of the fake clock
+func (f *Fake) Now() time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.now
+}
+
+// NewTimer returns a new timer that fires when the fake clock is advanced by
+// duration d
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	t := &fakeTimer{
+		clock: f,
+		when:  f.now.Add(d),
+		c:     make(chan time.Time, 1),
+	}
+	if d <= 0 {
+		t.c <- f.now
+		return t
+	}
+	f.timers = append(f.timers, t)
+	f.changed()
+	return t
+}
+
+// Advance advances the fake clock by duration d and fires all timers that
+// expire until then in the order of their expiry
+func (f *Fake) Advance(d time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.now = f.now.Add(d)
+	sort.SliceStable(f.timers, func(i, j int) bool {
+		return f.timers[i].when.Before(f.timers[j].when)
+	})
+	n := 0
+	for _, t := range f.timers {
+		if t.when.After(f.now) {
+			break
+		}
+		t.c <- t.when
+		n++
+	}
+	f.timers = f.timers[n:]
+	if n > 0 {
+		f.changed()
+	}
+}
+
+// Timers returns the number of pending timers of the fake clock
+func (f *Fake) Timers() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return len(f.timers)
+}
+
+// BlockUntil blocks until the fake clock has n pending timers
+func (f *Fake) BlockUntil(n int) {
+	for {
+		f.mutex.Lock()
+		pending := len(f.timers)
+		waiters := f.waiters
+		f.mutex.Unlock()
+
+		if pending == n {
+			return
+		}
+		<-waiters
+	}
+}
+
+// NewFake returns a new fake clock set to time now
+func NewFake(now time.Time) *Fake {
+	return &Fake{
+		now:     now,
+		waiters: make(chan struct{}),
+	}
+}
+
+// FakeRand is a Rand that returns predefined numbers
+type FakeRand struct {
+	mutex   sync.Mutex
+	Numbers []int64
+}
+
+// Int63n returns the next predefined number modulo n, or 0 if there are no
+// more numbers
+func (f *FakeRand) Int63n(n int64) int64 {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if len(f.Numbers) == 0 {
+		return 0
+	}
+	i := f.Numbers[0]
+	f.Numbers = f.Numbers[1:]
+	return i % n
+}
@@ -0,0 +1,82 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+// TestFake tests the fake clock and its timers
+func TestFake(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	c := NewFake(start)
+	fired := func(timer Timer) bool {
+		select {
+		case <-timer.C():
+			return true
+		default:
+			return false
+		}
+	}
+
+	// timer without duration fires immediately
+	if !fired(c.NewTimer(0)) {
+		t.Error("timer without duration not fired")
+	}
+
+	// timers fire when the clock is advanced past their expiry
+	t1 := c.NewTimer(time.Second)
+	t2 := c.NewTimer(2 * time.Second)
+	t3 := c.NewTimer(3 * time.Second)
+	if c.Timers() != 3 {
+		t.Errorf("got %d timers, want 3", c.Timers())
+	}
+	c.Advance(500 * time.Millisecond)
+	if fired(t1) || fired(t2) {
+		t.Error("timers fired too early")
+	}
+	c.Advance(1500 * time.Millisecond)
+	if !fired(t1) || !fired(t2) || fired(t3) {
+		t.Error("timers not fired after expiry")
+	}
+	if got := c.Now(); !got.Equal(start.Add(2 * time.Second)) {
+		t.Errorf("got %v, want %v", got, start.Add(2*time.Second))
+	}
+
+	// stopped timers do not fire
+	if !t3.Stop() || t3.Stop() || t1.Stop() {
+		t.Error("invalid stop result")
+	}
+	c.Advance(time.Minute)
+	if fired(t3) || c.Timers() != 0 {
+		t.Error("stopped timer fired")
+	}
+
+	// block until timers are pending
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		c.NewTimer(time.Second)
+	}()
+	c.BlockUntil(1)
+	if c.Timers() != 1 {
+		t.Errorf("got %d timers, want 1", c.Timers())
+	}
+}
+
+// TestRand tests the random sources
+func TestRand(t *testing.T) {
+	// random numbers within range
+	r := NewRand(1)
+	for i := 0; i < 100; i++ {
+		if n := r.Int63n(10); n < 0 || n >= 10 {
+			t.Errorf("got %d, want 0-9", n)
+		}
+	}
+
+	// predefined numbers
+	f := &FakeRand{Numbers: []int64{3, 12}}
+	for _, want := range []int64{3, 2, 0} {
+		if got := f.Int63n(10); got != want {
+			t.Errorf("got %d, want %d", got, want)
+		}
+	}
+}
@@ -2,7 +2,10 @@ package cmd
 
 import (
 	"flag"
+	"io/fs"
 	"log"
+	"os"
+	"strconv"
 
 	"github.com/hwipl/schedule-events/internal/client"
 	"github.com/hwipl/schedule-events/internal/command"
@@ -14,9 +17,25 @@ var (
 	// parsed command line arguments
 	commandsFile = "commands.json"
 	eventsFile   = "events.json"
+	finishedSize = event.FinishedSize
+	historySize  = event.HistorySize
 	operation    = "get-events"
+	previewRuns  = client.PreviewRuns
 	serverAddr   = "localhost:8080"
 	serverMode   = false
+	stateFile    = ""
+	tokensFile   = ""
+	token        = os.Getenv("SCHEDULE_EVENTS_TOKEN")
+	useTLS       = false
+	tlsCert      = ""
+	tlsKey       = ""
+	tlsClientCA  = ""
+	caFile       = ""
+	certFile     = ""
+	keyFile      = ""
+	socketMode   = "0600"
+	fileMode     fs.FileMode
+	maxRuns      = event.MaxConcurrentRuns
 )
 
 // parseCommandLine parses the command line arguments
@@ -26,11 +45,43 @@ func parseCommandLine() {
 		"read commands from `file`")
 	flag.StringVar(&eventsFile, "events", eventsFile,
 		"read events from `file`")
+	flag.IntVar(&historySize, "history", historySize,
+		"keep the last `number` of runs of each event")
+	flag.IntVar(&finishedSize, "finished", finishedSize,
+		"keep the last `number` of finished events (server only)")
 	flag.StringVar(&operation, "operation", operation,
 		"run `operation` on server")
+	flag.IntVar(&previewRuns, "preview", previewRuns,
+		"preview the next `number` of runs of each event")
 	flag.StringVar(&serverAddr, "address", serverAddr,
 		"listen on or connect to `addr`")
 	flag.BoolVar(&serverMode, "server", serverMode, "run as server")
+	flag.StringVar(&stateFile, "state", stateFile,
+		"persist events in state `file` (server only)")
+	flag.StringVar(&tokensFile, "tokens", tokensFile,
+		"read api tokens from `file` (server only)")
+	flag.StringVar(&token, "token", token,
+		"send api `token` to server (default $SCHEDULE_EVENTS_TOKEN)")
+	flag.BoolVar(&useTLS, "tls", useTLS,
+		"connect to server with tls (client only)")
+	flag.StringVar(&tlsCert, "tls-cert", tlsCert,
+		"use tls certificate in `file` (server only)")
+	flag.StringVar(&tlsKey, "tls-key", tlsKey,
+		"use tls key in `file` (server only)")
+	flag.StringVar(&tlsClientCA, "tls-client-ca", tlsClientCA,
+		"verify client certificates with ca certificates in `file` "+
+			"(server only)")
+	flag.StringVar(&caFile, "ca", caFile,
+		"verify server certificate with ca certificates in `file` "+
+			"(client only)")
+	flag.StringVar(&certFile, "cert", certFile,
+		"use tls client certificate in `file` (client only)")
+	flag.StringVar(&keyFile, "key", keyFile,
+		"use tls client key in `file` (client only)")
+	flag.StringVar(&socketMode, "socket-mode", socketMode,
+		"set permissions of unix socket to octal `mode` (server only)")
+	flag.IntVar(&maxRuns, "max-concurrent-runs", maxRuns,
+		"run at most `number` of commands concurrently (server only)")
 	flag.Parse()
 
 	// parse address
@@ -38,6 +89,47 @@ func parseCommandLine() {
 		log.Fatal("no address specified")
 	}
 
+	// parse unix socket permissions
+	mode, err := strconv.ParseUint(socketMode, 8, 32)
+	if err != nil || mode > 0777 {
+		log.Fatal("invalid socket mode")
+	}
+	fileMode = fs.FileMode(mode)
+
+	// parse history size
+	if historySize < 1 {
+		log.Fatal("invalid history size")
+	}
+	event.HistorySize = historySize
+
+	// parse number of finished events
+	if finishedSize < 0 {
+		log.Fatal("invalid number of finished events")
+	}
+	event.FinishedSize = finishedSize
+
+	// parse maximum number of concurrent runs
+	if maxRuns < 1 {
+		log.Fatal("invalid maximum number of concurrent runs")
+	}
+	event.MaxConcurrentRuns = maxRuns
+
+	// parse preview size
+	if previewRuns < 1 {
+		log.Fatal("invalid preview size")
+	}
+	client.PreviewRuns = previewRuns
+	client.Token = token
+
+	// parse tls client certificate and key
+	if (certFile == "") != (keyFile == "") {
+		log.Fatal("tls client certificate or key missing")
+	}
+	client.TLS = useTLS
+	client.CAFile = caFile
+	client.CertFile = certFile
+	client.KeyFile = keyFile
+
 	// parse commands file
 	if serverMode && commandsFile == "" {
 		log.Fatal("no commands file specified")
@@ -50,7 +142,7 @@ func parseCommandLine() {
 	}
 
 	// parse events file
-	err := event.EventsFromJSON(eventsFile)
+	err = event.EventsFromJSON(eventsFile)
 	if err != nil {
 		log.Println(err)
 	}
@@ -60,7 +152,15 @@ func parseCommandLine() {
 func Run() {
 	parseCommandLine()
 	if serverMode {
-		server.Run(serverAddr)
+		server.Run(&server.Config{
+			Address:     serverAddr,
+			StateFile:   stateFile,
+			TokensFile:  tokensFile,
+			TLSCert:     tlsCert,
+			TLSKey:      tlsKey,
+			TLSClientCA: tlsClientCA,
+			SocketMode:  fileMode,
+		})
 		return
 	}
 	client.Run(serverAddr, operation)
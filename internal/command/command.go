@@ -1,8 +1,12 @@
 package command
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
+	"log"
 	"os"
 	"os/exec"
 	"sort"
@@ -10,6 +14,20 @@ import (
 	"time"
 )
 
+const (
+	// DefaultMaxOutput is the default maximum size of the captured stdout
+	// and stderr of a command run
+	DefaultMaxOutput = 64 * 1024
+
+	// DefaultKillGrace is the default time between sending the stop
+	// signal to a command and killing it
+	DefaultKillGrace = 5 * time.Second
+
+	// truncatedMarker is appended to captured output that exceeded the
+	// maximum size
+	truncatedMarker = "\n[output truncated]\n"
+)
+
 var (
 	// commands stores a list of all commands
 	commands = newCommandList()
@@ -60,21 +78,344 @@ func newCommandList() *commandList {
 	}
 }
 
+// outputBuffer is a buffer for command output that stores at most max bytes
+type outputBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+// Write writes p to the buffer and discards everything beyond the maximum
+// size
+func (o *outputBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if free := o.max - o.buf.Len(); n > free {
+		p = p[:free]
+		o.truncated = true
+	}
+	o.buf.Write(p)
+	return n, nil
+}
+
+// String returns the content of the buffer including the truncation marker
+// if output was discarded
+func (o *outputBuffer) String() string {
+	if o.truncated {
+		return o.buf.String() + truncatedMarker
+	}
+	return o.buf.String()
+}
+
+// newOutputBuffer returns a new outputBuffer with maximum size max
+func newOutputBuffer(max int) *outputBuffer {
+	return &outputBuffer{
+		max: max,
+	}
+}
+
+// Options are options of a command run
+type Options struct {
+	// Timeout overrides the timeout of the command; it is capped by the
+	// timeout of the command, zero means no override
+	Timeout time.Duration
+
+	// Env contains additional environment variables in the form
+	// "key=value"; they override the environment variables of the command
+	Env []string
+
+	// Parameters contains the values of the command's parameters
+	Parameters map[string]string
+}
+
+// Result is the result of a command run
+type Result struct {
+	Timeout         time.Duration
+	ExitCode        int
+	TimedOut        bool
+	Canceled        bool
+	Terminated      bool
+	Killed          bool
+	Stdout          string
+	Stderr          string
+	StdoutTruncated bool
+	StderrTruncated bool
+}
+
 // Command is an executable command
 type Command struct {
 	Name       string
 	Executable string
 	Arguments  []string
 	Timeout    time.Duration
+	MaxOutput  int
+	Env        map[string]string
+	ClearEnv   bool
+	Dir        string
+	Parameters map[string]*Parameter
+	StopSignal string
+	KillGrace  time.Duration
 }
 
-// Run executes the command
-func (c *Command) Run() error {
-	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+// check checks if the definition of the command is valid
+func (c *Command) check() error {
+	if _, err := parseSignal(c.StopSignal); err != nil {
+		return fmt.Errorf("command %s: %w", c.Name, err)
+	}
+	return c.checkParameterDefinitions()
+}
+
+// killGrace returns the time between sending the stop signal to the command
+// and killing it
+func (c *Command) killGrace() time.Duration {
+	if c.KillGrace <= 0 {
+		return DefaultKillGrace
+	}
+	return c.KillGrace
+}
+
+// maxOutput returns the maximum size of the captured stdout and stderr
+func (c *Command) maxOutput() int {
+	if c.MaxOutput <= 0 {
+		return DefaultMaxOutput
+	}
+	return c.MaxOutput
+}
+
+// environ returns the environment of a command run with the additional
+// environment variables in env
+func (c *Command) environ(env []string) []string {
+	environ := []string{}
+	if !c.ClearEnv {
+		environ = os.Environ()
+	}
+
+	// add command's environment variables sorted by their name
+	keys := []string{}
+	for k := range c.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		environ = append(environ, k+"="+c.Env[k])
+	}
+
+	// add run's environment variables, duplicates override existing ones
+	return append(environ, env...)
+}
+
+// EffectiveTimeout returns the timeout of a command run with the timeout
+// override in timeout; zero means no timeout
+func (c *Command) EffectiveTimeout(timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return max(c.Timeout, 0)
+	}
+	if c.Timeout <= 0 {
+		return timeout
+	}
+	return min(c.Timeout, timeout)
+}
+
+// Run executes the command with the options in opts and returns its captured
+// output; the command is stopped when ctx is done, opts may be nil
+func (c *Command) Run(ctx context.Context, opts *Options) (*Result, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	if err := c.CheckParameters(opts.Parameters); err != nil {
+		return &Result{ExitCode: -1}, err
+	}
+
+	// create context with the effective timeout, if any
+	timeout := c.EffectiveTimeout(opts.Timeout)
+	parent := ctx
+	ctx, cancel := context.WithCancel(parent)
 	defer cancel()
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// create command in its own process group
+	stdout := newOutputBuffer(c.maxOutput())
+	stderr := newOutputBuffer(c.maxOutput())
+	args := c.arguments(opts.Parameters)
+	cmd := exec.Command(c.Executable, args...)
+	cmd.Env = c.environ(opts.Env)
+	cmd.Dir = c.Dir
+	setProcessGroup(cmd)
+
+	// run command
+	result := &Result{
+		Timeout:  timeout,
+		ExitCode: -1,
+	}
+	stopSignal, err := parseSignal(c.StopSignal)
+	if err != nil {
+		return result, err
+	}
+	if err := ctx.Err(); err != nil {
+		// do not start the command if the context is already done
+		result.Canceled = parent.Err() != nil
+		result.TimedOut = !result.Canceled
+		return result, err
+	}
+	output, err := newOutput(cmd, stdout, stderr)
+	if err != nil {
+		return result, err
+	}
+	if err := cmd.Start(); err != nil {
+		output.close()
+		return result, err
+	}
+	copied := output.copy()
+	exited := make(chan struct{})
+	go func() {
+		err = cmd.Wait()
+		close(exited)
+	}()
+
+	// wait for command; when the context is done, send the stop signal to
+	// the process group and kill it after the grace period
+	select {
+	case <-exited:
+	case <-ctx.Done():
+		result.Canceled = parent.Err() != nil
+		result.TimedOut = !result.Canceled
+		result.Terminated, result.Killed = c.stop(cmd, stopSignal,
+			exited)
+
+		// stopped runs fail even if the command exits successfully
+		// after the stop signal
+		if err == nil {
+			err = ctx.Err()
+		} else {
+			err = fmt.Errorf("%w: %w", ctx.Err(), err)
+		}
+	}
+
+	// wait for the output of the command at most for the grace period
+	// after it exited, so background processes holding the output do not
+	// block the run
+	timer := time.NewTimer(c.killGrace())
+	select {
+	case <-copied:
+		timer.Stop()
+	case <-timer.C:
+		output.closeRead()
+		<-copied
+	}
+
+	// get exit code, -1 if the command was terminated by a signal
+	result.ExitCode = cmd.ProcessState.ExitCode()
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	result.StdoutTruncated = stdout.truncated
+	result.StderrTruncated = stderr.truncated
+	return result, err
+}
+
+// stop sends the stop signal sig to the process group of the started cmd and
+// kills the process group after the grace period, even if cmd exited before;
+// exited is closed when cmd exited. It returns whether cmd exited after the
+// stop signal or was killed
+func (c *Command) stop(cmd *exec.Cmd, sig os.Signal,
+	exited <-chan struct{}) (terminated, killed bool) {
+	if err := signalProcessGroup(cmd, sig); err != nil {
+		log.Println(err)
+	}
+	timer := time.NewTimer(c.killGrace())
+	defer timer.Stop()
+	select {
+	case <-exited:
+		terminated = true
+	case <-timer.C:
+		if err := signalProcessGroup(cmd, os.Kill); err != nil {
+			log.Println(err)
+		}
+		<-exited
+		return false, true
+	}
+
+	// kill other processes of the process group after the grace period
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for processGroupAlive(cmd) {
+		select {
+		case <-timer.C:
+			if err := signalProcessGroup(cmd, os.Kill); err != nil {
+				log.Println(err)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+	return
+}
 
-	cmd := exec.CommandContext(ctx, c.Executable, c.Arguments...)
-	return cmd.Run()
+// output is the stdout and stderr of a command run; the command writes to
+// pipes that are copied into output buffers, so waiting for the command does
+// not wait for processes that inherited the pipes
+type output struct {
+	files   [2]*os.File
+	pipes   [2]*os.File
+	buffers [2]*outputBuffer
+}
+
+// copy copies the pipes into the output buffers after the start of the
+// command; the returned channel is closed when all output is copied
+func (o *output) copy() <-chan struct{} {
+	// close write ends in this process
+	for _, f := range o.files {
+		f.Close()
+	}
+
+	var wg sync.WaitGroup
+	for i := range o.pipes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			io.Copy(o.buffers[i], o.pipes[i])
+		}()
+	}
+	copied := make(chan struct{})
+	go func() {
+		wg.Wait()
+		o.closeRead()
+		close(copied)
+	}()
+	return copied
+}
+
+// closeRead closes the read ends of the pipes
+func (o *output) closeRead() {
+	for _, p := range o.pipes {
+		p.Close()
+	}
+}
+
+// close closes the pipes
+func (o *output) close() {
+	for _, f := range o.files {
+		f.Close()
+	}
+	o.closeRead()
+}
+
+// newOutput returns new output pipes of cmd into the buffers stdout and
+// stderr
+func newOutput(cmd *exec.Cmd, stdout, stderr *outputBuffer) (*output,
+	error) {
+	o := &output{buffers: [2]*outputBuffer{stdout, stderr}}
+	for i := range o.pipes {
+		r, w, err := os.Pipe()
+		if err != nil {
+			o.close()
+			return nil, err
+		}
+		o.pipes[i], o.files[i] = r, w
+	}
+	cmd.Stdout, cmd.Stderr = o.files[0], o.files[1]
+	return o, nil
 }
 
 // Add adds command to the command list
@@ -107,6 +448,13 @@ func CommandsFromJSON(path string) error {
 		return err
 	}
 
+	// check commands
+	for _, c := range cmds {
+		if err := c.check(); err != nil {
+			return err
+		}
+	}
+
 	// add commands to command list
 	for _, c := range cmds {
 		Add(c)
@@ -1,6 +1,10 @@
 package command
 
 import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
@@ -106,25 +110,297 @@ func TestCommandRun(t *testing.T) {
 		Arguments:  []string{"1"},
 	}
 
-	// test timeout, no args
-	if err := cmd1.Run(); err == nil {
-		t.Errorf("got %v, want !nil", err)
+	// test successful run without timeout, no args
+	if _, err := cmd1.Run(context.Background(), nil); err != nil {
+		t.Errorf("got %v, want %v", err, nil)
+	}
+
+	// test successful run without timeout, with args
+	if _, err := cmd2.Run(context.Background(), nil); err != nil {
+		t.Errorf("got %v, want %v", err, nil)
 	}
 
 	// test timeout, with args
-	if err := cmd2.Run(); err == nil {
+	cmd2.Timeout = 100 * time.Millisecond
+	if _, err := cmd2.Run(context.Background(), nil); err == nil {
 		t.Errorf("got %v, want !nil", err)
 	}
 
 	// test successful run, no args
 	cmd1.Timeout = 10 * time.Second
-	if err := cmd1.Run(); err != nil {
+	if _, err := cmd1.Run(context.Background(), nil); err != nil {
 		t.Errorf("got %v, want %v", err, nil)
 	}
 
 	// test successful run, with args
 	cmd2.Timeout = 10 * time.Second
-	if err := cmd2.Run(); err != nil {
+	if _, err := cmd2.Run(context.Background(), nil); err != nil {
 		t.Errorf("got %v, want %v", err, nil)
 	}
+
+	// test timeout override, with args
+	res, err := cmd2.Run(context.Background(), &Options{Timeout: 100 * time.Millisecond})
+	if err == nil {
+		t.Errorf("got %v, want !nil", err)
+	}
+	if res.Timeout != 100*time.Millisecond || !res.TimedOut {
+		t.Errorf("got %v, %t, want 100ms, true", res.Timeout,
+			res.TimedOut)
+	}
+}
+
+// TestCommandRunCancel tests canceling command runs
+func TestCommandRunCancel(t *testing.T) {
+	cmd := &Command{
+		Name:       "sleep",
+		Executable: "sleep",
+		Arguments:  []string{"10"},
+		Timeout:    10 * time.Second,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(),
+		100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	res, err := cmd.Run(ctx, nil)
+	if err == nil {
+		t.Errorf("got nil, want !nil")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("command stopped after %v", d)
+	}
+	if res.TimedOut || !res.Canceled || !res.Terminated {
+		t.Errorf("got %+v, want canceled and terminated", res)
+	}
+
+	// canceled before the start, command is not started
+	file := filepath.Join(t.TempDir(), "started")
+	cmd = &Command{
+		Name:       "touch",
+		Executable: "touch",
+		Arguments:  []string{file},
+	}
+	res, err = cmd.Run(ctx, nil)
+	if !errors.Is(err, context.DeadlineExceeded) || !res.Canceled {
+		t.Errorf("got %v, %+v, want canceled", err, res)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Error("canceled command started")
+	}
+}
+
+// TestCommandRunStopSignalExit tests stopping commands that exit successfully
+// after the stop signal
+func TestCommandRunStopSignalExit(t *testing.T) {
+	cmd := &Command{
+		Name:       "trap",
+		Executable: "sh",
+		Arguments:  []string{"-c", "trap 'exit 0' TERM; sleep 5 & wait"},
+		Timeout:    200 * time.Millisecond,
+	}
+
+	// timeout
+	res, err := cmd.Run(context.Background(), nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if !res.TimedOut || res.ExitCode != 0 {
+		t.Errorf("got %+v, want timed out with exit code 0", res)
+	}
+
+	// cancel
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	res, err = cmd.Run(ctx, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+	if !res.Canceled {
+		t.Errorf("got %+v, want canceled", res)
+	}
+}
+
+// TestCommandEffectiveTimeout tests getting the effective timeout of
+// command runs
+func TestCommandEffectiveTimeout(t *testing.T) {
+	for _, test := range []struct {
+		cmd      time.Duration
+		override time.Duration
+		want     time.Duration
+	}{
+		// no timeouts
+		{0, 0, 0},
+		{-1, -1, 0},
+
+		// command timeout only
+		{time.Second, 0, time.Second},
+
+		// override timeout only
+		{0, time.Second, time.Second},
+
+		// override timeout capped by command timeout
+		{time.Second, 2 * time.Second, time.Second},
+		{2 * time.Second, time.Second, time.Second},
+	} {
+		cmd := &Command{Timeout: test.cmd}
+		got := cmd.EffectiveTimeout(test.override)
+		if got != test.want {
+			t.Errorf("got %v, want %v", got, test.want)
+		}
+	}
+}
+
+// TestCommandRunOutput tests capturing the output of commands
+func TestCommandRunOutput(t *testing.T) {
+	cmd := &Command{
+		Name:       "echo",
+		Executable: "sh",
+		Arguments:  []string{"-c", "echo out; echo err >&2; exit 3"},
+		Timeout:    10 * time.Second,
+	}
+	test := func(want, got *Result) {
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+
+	// test output below maximum size
+	res, err := cmd.Run(context.Background(), nil)
+	if err == nil {
+		t.Errorf("got %v, want !nil", err)
+	}
+	test(&Result{
+		Timeout:  10 * time.Second,
+		ExitCode: 3,
+		Stdout:   "out\n",
+		Stderr:   "err\n",
+	}, res)
+
+	// test truncated output
+	cmd.MaxOutput = 2
+	res, err = cmd.Run(context.Background(), nil)
+	if err == nil {
+		t.Errorf("got %v, want !nil", err)
+	}
+	test(&Result{
+		Timeout:         10 * time.Second,
+		ExitCode:        3,
+		Stdout:          "ou" + truncatedMarker,
+		Stderr:          "er" + truncatedMarker,
+		StdoutTruncated: true,
+		StderrTruncated: true,
+	}, res)
+}
+
+// TestCommandRunResult tests the exit status in results of command runs
+func TestCommandRunResult(t *testing.T) {
+	test := func(want, got *Result) {
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+
+	// test command that cannot be started
+	cmd := &Command{
+		Name:       "invalid",
+		Executable: "does-not-exist",
+		Timeout:    10 * time.Second,
+	}
+	res, _ := cmd.Run(context.Background(), nil)
+	test(&Result{Timeout: 10 * time.Second, ExitCode: -1}, res)
+
+	// test successful command
+	cmd = &Command{
+		Name:       "true",
+		Executable: "true",
+		Timeout:    10 * time.Second,
+	}
+	res, _ = cmd.Run(context.Background(), nil)
+	test(&Result{Timeout: 10 * time.Second}, res)
+
+	// test command killed after timeout
+	cmd = &Command{
+		Name:       "sleep",
+		Executable: "sleep",
+		Arguments:  []string{"10"},
+		Timeout:    100 * time.Millisecond,
+	}
+	res, _ = cmd.Run(context.Background(), nil)
+	test(&Result{
+		Timeout:    100 * time.Millisecond,
+		ExitCode:   -1,
+		TimedOut:   true,
+		Terminated: true,
+	}, res)
+}
+
+// TestCommandRunEnv tests running commands with environment variables and
+// working directory
+func TestCommandRunEnv(t *testing.T) {
+	t.Setenv("SCHEDULE_TEST_INHERITED", "inherited")
+	cmd := &Command{
+		Name:       "env",
+		Executable: "sh",
+		Arguments: []string{"-c", "echo $SCHEDULE_TEST_INHERITED " +
+			"$SCHEDULE_TEST_CMD $SCHEDULE_TEST_RUN; pwd"},
+		Timeout: 10 * time.Second,
+		Env: map[string]string{
+			"SCHEDULE_TEST_CMD": "cmd",
+			"SCHEDULE_TEST_RUN": "cmd",
+		},
+		Dir: "/",
+	}
+	test := func(want string, opts *Options) {
+		res, err := cmd.Run(context.Background(), opts)
+		if err != nil {
+			t.Errorf("got %v, want nil", err)
+		}
+		if res.Stdout != want {
+			t.Errorf("got %q, want %q", res.Stdout, want)
+		}
+	}
+
+	// test inherited and command environment variables
+	test("inherited cmd cmd\n/\n", nil)
+
+	// test run environment variables overriding command variables
+	test("inherited cmd run\n/\n", &Options{
+		Env: []string{"SCHEDULE_TEST_RUN=run"},
+	})
+
+	// test cleared environment
+	cmd.ClearEnv = true
+	test("cmd run\n/\n", &Options{
+		Env: []string{"SCHEDULE_TEST_RUN=run"},
+	})
+}
+
+// TestOutputBuffer tests writing to an outputBuffer
+func TestOutputBuffer(t *testing.T) {
+	test := func(want, got string) {
+		if got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+
+	// test empty buffer
+	buf := newOutputBuffer(8)
+	test("", buf.String())
+
+	// test writes below maximum size
+	buf.Write([]byte("1234"))
+	buf.Write([]byte("5678"))
+	test("12345678", buf.String())
+
+	// test writes beyond maximum size
+	n, err := buf.Write([]byte("9"))
+	if n != 1 || err != nil {
+		t.Errorf("got %d, %v, want 1, nil", n, err)
+	}
+	test("12345678"+truncatedMarker, buf.String())
+
+	// test single write beyond maximum size
+	buf = newOutputBuffer(2)
+	buf.Write([]byte("1234"))
+	test("12"+truncatedMarker, buf.String())
 }
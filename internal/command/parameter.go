@@ -0,0 +1,155 @@
+package command
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// parameter types
+	ParameterString = "string"
+	ParameterInt    = "int"
+	ParameterBool   = "bool"
+)
+
+// Parameter is a parameter of a command; it is referenced in the command's
+// arguments with the placeholder "{{name}}"
+type Parameter struct {
+	Type    string
+	Pattern string
+	Enum    []string
+	Default *string
+}
+
+// placeholder returns the placeholder of the parameter with name
+func placeholder(name string) string {
+	return "{{" + name + "}}"
+}
+
+// check checks if the definition of the parameter is valid
+func (p *Parameter) check() error {
+	switch p.Type {
+	case "", ParameterString, ParameterInt, ParameterBool:
+	default:
+		return fmt.Errorf("invalid type: %s", p.Type)
+	}
+	if _, err := regexp.Compile(p.Pattern); err != nil {
+		return err
+	}
+	for _, v := range p.Enum {
+		if err := p.checkValue(v); err != nil {
+			return err
+		}
+	}
+	if p.Default != nil {
+		return p.checkValue(*p.Default)
+	}
+	return nil
+}
+
+// checkValue checks if value is a valid value of the parameter
+func (p *Parameter) checkValue(value string) error {
+	// check type
+	switch p.Type {
+	case ParameterInt:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("invalid int value: %q", value)
+		}
+	case ParameterBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("invalid bool value: %q", value)
+		}
+	}
+
+	// check pattern, must match the whole value
+	if p.Pattern != "" {
+		re, err := regexp.Compile("^(?:" + p.Pattern + ")$")
+		if err != nil {
+			return err
+		}
+		if !re.MatchString(value) {
+			return fmt.Errorf("value %q does not match pattern %q",
+				value, p.Pattern)
+		}
+	}
+
+	// check enum
+	if len(p.Enum) > 0 && !slices.Contains(p.Enum, value) {
+		return fmt.Errorf("value %q not in %q", value, p.Enum)
+	}
+	return nil
+}
+
+// checkParameterDefinitions checks if the definitions of the parameters of
+// the command are valid and all placeholders in its arguments reference
+// defined parameters
+func (c *Command) checkParameterDefinitions() error {
+	for name, p := range c.Parameters {
+		if p == nil {
+			return fmt.Errorf("command %s: parameter %s: "+
+				"missing definition", c.Name, name)
+		}
+		if err := p.check(); err != nil {
+			return fmt.Errorf("command %s: parameter %s: %w",
+				c.Name, name, err)
+		}
+	}
+	re := regexp.MustCompile(`\{\{([^{}]*)\}\}`)
+	for _, a := range c.Arguments {
+		for _, m := range re.FindAllStringSubmatch(a, -1) {
+			if _, ok := c.Parameters[m[1]]; !ok {
+				return fmt.Errorf("command %s: undefined "+
+					"parameter: %s", c.Name, m[1])
+			}
+		}
+	}
+	return nil
+}
+
+// CheckParameters checks if the parameter values in params are valid for
+// the command; parameters without default value are required
+func (c *Command) CheckParameters(params map[string]string) error {
+	for name := range params {
+		if _, ok := c.Parameters[name]; !ok {
+			return fmt.Errorf("unknown parameter: %s", name)
+		}
+	}
+	for name, p := range c.Parameters {
+		value, ok := params[name]
+		if !ok {
+			if p.Default == nil {
+				return fmt.Errorf("missing parameter: %s", name)
+			}
+			continue
+		}
+		if err := p.checkValue(value); err != nil {
+			return fmt.Errorf("parameter %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// arguments returns the command's arguments with the placeholders replaced
+// by the values in params or the default values of the parameters
+func (c *Command) arguments(params map[string]string) []string {
+	if len(c.Parameters) == 0 {
+		return c.Arguments
+	}
+	oldnew := []string{}
+	for name, p := range c.Parameters {
+		value, ok := params[name]
+		if !ok && p.Default != nil {
+			value = *p.Default
+		}
+		oldnew = append(oldnew, placeholder(name), value)
+	}
+	r := strings.NewReplacer(oldnew...)
+	args := make([]string, len(c.Arguments))
+	for i, a := range c.Arguments {
+		args[i] = r.Replace(a)
+	}
+	return args
+}
@@ -0,0 +1,182 @@
+package command
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestParameterCheck tests checking parameter definitions
+func TestParameterCheck(t *testing.T) {
+	def := "5"
+	invalidDef := "five"
+
+	// valid definitions
+	for _, p := range []*Parameter{
+		{},
+		{Type: ParameterString, Pattern: "[a-z]+"},
+		{Type: ParameterInt, Enum: []string{"1", "2"}},
+		{Type: ParameterInt, Default: &def},
+		{Type: ParameterBool},
+	} {
+		if err := p.check(); err != nil {
+			t.Errorf("%+v: got %v, want nil", p, err)
+		}
+	}
+
+	// invalid definitions
+	for _, p := range []*Parameter{
+		{Type: "float"},
+		{Pattern: "[a-z"},
+		{Type: ParameterInt, Enum: []string{"1", "two"}},
+		{Type: ParameterInt, Default: &invalidDef},
+		{Pattern: "[0-9]", Default: &invalidDef},
+	} {
+		if err := p.check(); err == nil {
+			t.Errorf("%+v: got nil, want !nil", p)
+		}
+	}
+}
+
+// TestCommandCheckParameters tests checking parameter values of commands
+func TestCommandCheckParameters(t *testing.T) {
+	def := "info"
+	cmd := &Command{
+		Name: "params",
+		Parameters: map[string]*Parameter{
+			"count": {Type: ParameterInt},
+			"host":  {Pattern: `[a-z0-9.-]+`},
+			"level": {Enum: []string{"debug", "info"}, Default: &def},
+		},
+	}
+
+	// valid parameters
+	for _, params := range []map[string]string{
+		{"count": "3", "host": "localhost"},
+		{"count": "-1", "host": "10.0.0.1", "level": "debug"},
+	} {
+		if err := cmd.CheckParameters(params); err != nil {
+			t.Errorf("%v: got %v, want nil", params, err)
+		}
+	}
+
+	// invalid parameters
+	for _, params := range []map[string]string{
+		nil,
+		{"count": "3"},
+		{"count": "three", "host": "localhost"},
+		{"count": "3", "host": "localhost; rm -rf /"},
+		{"count": "3", "host": "localhost", "level": "trace"},
+		{"count": "3", "host": "localhost", "unknown": "x"},
+	} {
+		if err := cmd.CheckParameters(params); err == nil {
+			t.Errorf("%v: got nil, want !nil", params)
+		}
+	}
+}
+
+// TestCommandArguments tests replacing placeholders in command arguments
+func TestCommandArguments(t *testing.T) {
+	def := "info"
+	cmd := &Command{
+		Name:      "params",
+		Arguments: []string{"-c", "{{count}}", "--level={{level}}"},
+		Parameters: map[string]*Parameter{
+			"count": {Type: ParameterInt},
+			"level": {Default: &def},
+		},
+	}
+	test := func(want, got []string) {
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+
+	// test with default value
+	test([]string{"-c", "3", "--level=info"},
+		cmd.arguments(map[string]string{"count": "3"}))
+
+	// test with all values
+	test([]string{"-c", "3", "--level=debug"},
+		cmd.arguments(map[string]string{
+			"count": "3",
+			"level": "debug",
+		}))
+
+	// test command without parameters
+	cmd = &Command{Arguments: []string{"{{count}}"}}
+	test([]string{"{{count}}"}, cmd.arguments(nil))
+}
+
+// TestCommandRunParameters tests running commands with parameters
+func TestCommandRunParameters(t *testing.T) {
+	cmd := &Command{
+		Name:       "echo",
+		Executable: "echo",
+		Arguments:  []string{"{{text}}"},
+		Timeout:    10 * time.Second,
+		Parameters: map[string]*Parameter{
+			"text": {Pattern: "[a-z ]+"},
+		},
+	}
+
+	// test valid parameters
+	res, err := cmd.Run(context.Background(), &Options{
+		Parameters: map[string]string{"text": "hello world"},
+	})
+	if err != nil || res.Stdout != "hello world\n" {
+		t.Errorf("got %q, %v, want \"hello world\\n\", nil",
+			res.Stdout, err)
+	}
+
+	// test invalid parameters
+	_, err = cmd.Run(context.Background(), &Options{
+		Parameters: map[string]string{"text": "$(reboot)"},
+	})
+	if err == nil {
+		t.Errorf("got nil, want !nil")
+	}
+}
+
+// TestCommandsFromJSONParameters tests loading commands with parameters
+func TestCommandsFromJSONParameters(t *testing.T) {
+	test := func(valid bool, content string) {
+		path := filepath.Join(t.TempDir(), "commands.json")
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		err := CommandsFromJSON(path)
+		if valid && err != nil {
+			t.Errorf("got %v, want nil", err)
+		}
+		if !valid && err == nil {
+			t.Errorf("got nil, want !nil")
+		}
+	}
+
+	// test valid parameters
+	test(true, `[{
+		"Name": "test-params-valid",
+		"Executable": "echo",
+		"Arguments": ["{{text}}"],
+		"Parameters": {"text": {"Type": "string"}}
+	}]`)
+
+	// test undefined parameter
+	test(false, `[{
+		"Name": "test-params-undefined",
+		"Executable": "echo",
+		"Arguments": ["{{text}}"]
+	}]`)
+
+	// test invalid parameter definition
+	test(false, `[{
+		"Name": "test-params-invalid",
+		"Executable": "echo",
+		"Arguments": ["{{text}}"],
+		"Parameters": {"text": {"Type": "float"}}
+	}]`)
+}
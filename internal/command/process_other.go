@@ -0,0 +1,41 @@
+//go:build !unix
+
+package command
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// parseSignal returns the signal identified by name; only "SIGKILL" and
+// "SIGINT" are supported, an empty name returns SIGKILL
+func parseSignal(name string) (os.Signal, error) {
+	name = strings.ToUpper(name)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	switch name {
+	case "SIG", "SIGKILL":
+		return os.Kill, nil
+	case "SIGINT":
+		return os.Interrupt, nil
+	}
+	return nil, fmt.Errorf("invalid signal: %s", name)
+}
+
+// setProcessGroup does nothing, process groups are not supported
+func setProcessGroup(cmd *exec.Cmd) {
+}
+
+// signalProcessGroup sends sig to the started cmd, process groups are not
+// supported
+func signalProcessGroup(cmd *exec.Cmd, sig os.Signal) error {
+	return cmd.Process.Signal(sig)
+}
+
+// processGroupAlive returns false, process groups are not supported
+func processGroupAlive(cmd *exec.Cmd) bool {
+	return false
+}
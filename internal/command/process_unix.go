@@ -0,0 +1,58 @@
+//go:build unix
+
+package command
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+var (
+	// signals maps signal names to the signals that can be used to stop
+	// commands
+	signals = map[string]syscall.Signal{
+		"SIGHUP":  syscall.SIGHUP,
+		"SIGINT":  syscall.SIGINT,
+		"SIGQUIT": syscall.SIGQUIT,
+		"SIGKILL": syscall.SIGKILL,
+		"SIGTERM": syscall.SIGTERM,
+		"SIGUSR1": syscall.SIGUSR1,
+		"SIGUSR2": syscall.SIGUSR2,
+	}
+)
+
+// parseSignal returns the signal identified by name, e.g., "SIGTERM" or
+// "TERM"; an empty name returns SIGTERM
+func parseSignal(name string) (os.Signal, error) {
+	if name == "" {
+		return syscall.SIGTERM, nil
+	}
+	name = strings.ToUpper(name)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	sig, ok := signals[name]
+	if !ok {
+		return nil, fmt.Errorf("invalid signal: %s", name)
+	}
+	return sig, nil
+}
+
+// setProcessGroup sets cmd to start in its own process group
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// signalProcessGroup sends sig to the process group of the started cmd
+func signalProcessGroup(cmd *exec.Cmd, sig os.Signal) error {
+	return syscall.Kill(-cmd.Process.Pid, sig.(syscall.Signal))
+}
+
+// processGroupAlive returns whether processes of the process group of the
+// started cmd are still running
+func processGroupAlive(cmd *exec.Cmd) bool {
+	return syscall.Kill(-cmd.Process.Pid, 0) == nil
+}
@@ -0,0 +1,141 @@
+//go:build unix
+
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestParseSignal tests parsing signal names
+func TestParseSignal(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		want os.Signal
+	}{
+		{"", syscall.SIGTERM},
+		{"SIGTERM", syscall.SIGTERM},
+		{"term", syscall.SIGTERM},
+		{"SIGINT", syscall.SIGINT},
+		{"KILL", syscall.SIGKILL},
+	} {
+		got, err := parseSignal(test.name)
+		if err != nil || got != test.want {
+			t.Errorf("%q: got %v, %v, want %v, nil", test.name, got,
+				err, test.want)
+		}
+	}
+	if _, err := parseSignal("SIGFOO"); err == nil {
+		t.Errorf("got nil, want !nil")
+	}
+}
+
+// TestCommandRunStop tests stopping commands after a timeout
+func TestCommandRunStop(t *testing.T) {
+	test := func(cmd *Command, terminated, killed bool) {
+		start := time.Now()
+		res, err := cmd.Run(context.Background(), nil)
+		if err == nil {
+			t.Errorf("got nil, want !nil")
+		}
+		if !res.TimedOut ||
+			res.Terminated != terminated ||
+			res.Killed != killed {
+			t.Errorf("got %+v, want terminated %t, killed %t",
+				res, terminated, killed)
+		}
+		if d := time.Since(start); d > 5*time.Second {
+			t.Errorf("command stopped after %v", d)
+		}
+	}
+
+	// test stopping process tree with stop signal
+	test(&Command{
+		Name:       "tree",
+		Executable: "sh",
+		Arguments:  []string{"-c", "sleep 10 & sleep 10; wait"},
+		Timeout:    100 * time.Millisecond,
+	}, true, false)
+
+	// test custom stop signal
+	test(&Command{
+		Name:       "int",
+		Executable: "sleep",
+		Arguments:  []string{"10"},
+		Timeout:    100 * time.Millisecond,
+		StopSignal: "SIGINT",
+	}, true, false)
+
+	// test killing process tree that ignores the stop signal
+	test(&Command{
+		Name:       "ignore",
+		Executable: "sh",
+		Arguments: []string{"-c",
+			"trap '' TERM; sleep 10 & sleep 10; wait"},
+		Timeout:   100 * time.Millisecond,
+		KillGrace: 200 * time.Millisecond,
+	}, false, true)
+}
+
+// TestCommandRunStopProcessGroup tests killing processes of the process group
+// that ignore the stop signal after the command exited
+func TestCommandRunStopProcessGroup(t *testing.T) {
+	cmd := &Command{
+		Name:       "group",
+		Executable: "sh",
+		Arguments: []string{"-c", "(trap '' TERM; exec sleep 10) " +
+			">/dev/null 2>&1 & echo $!; wait"},
+		Timeout:   100 * time.Millisecond,
+		KillGrace: 200 * time.Millisecond,
+	}
+	res, err := cmd.Run(context.Background(), nil)
+	if err == nil || !res.TimedOut || !res.Terminated {
+		t.Errorf("got %v, %+v, want timed out and terminated", err, res)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(res.Stdout))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// background process is killed or a zombie
+	for i := 0; ; i++ {
+		stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		if err != nil || strings.Contains(string(stat), ") Z ") {
+			break
+		}
+		if i == 100 {
+			t.Fatal("background process not killed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestCommandRunBackgroundOutput tests runs with background processes that
+// keep the output open after the command exited
+func TestCommandRunBackgroundOutput(t *testing.T) {
+	for _, timeout := range []time.Duration{10 * time.Second, 0} {
+		cmd := &Command{
+			Name:       "background",
+			Executable: "sh",
+			Arguments:  []string{"-c", "sleep 3 & echo ok"},
+			Timeout:    timeout,
+			KillGrace:  100 * time.Millisecond,
+		}
+		start := time.Now()
+		res, err := cmd.Run(context.Background(), nil)
+		if err != nil || res.ExitCode != 0 || res.Stdout != "ok\n" {
+			t.Errorf("timeout %v: got %v, %+v, want successful run",
+				timeout, err, res)
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("timeout %v: command returned after %v",
+				timeout, d)
+		}
+	}
+}
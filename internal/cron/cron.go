@@ -0,0 +1,300 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// field is the definition of a field in a cron expression
+type field struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var (
+	// fields of a cron expression including the optional seconds field
+	seconds = field{name: "second", min: 0, max: 59}
+	minutes = field{name: "minute", min: 0, max: 59}
+	hours   = field{name: "hour", min: 0, max: 23}
+	days    = field{name: "day of month", min: 1, max: 31}
+	months  = field{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	weekdays = field{name: "day of week", min: 0, max: 7,
+		names: map[string]int{
+			"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4,
+			"fri": 5, "sat": 6,
+		}}
+
+	// descriptors are predefined cron expressions
+	descriptors = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+)
+
+// value parses a single value s of the field
+func (f *field) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s: %s", f.name, s)
+	}
+	return v, nil
+}
+
+// parse parses the field in s and returns the matching values as bit set
+func (f *field) parse(s string) (uint64, error) {
+	bits := uint64(0)
+	for _, item := range strings.Split(s, ",") {
+		// get step
+		rng, step, hasStep := strings.Cut(item, "/")
+		inc := 1
+		if hasStep {
+			i, err := strconv.Atoi(step)
+			if err != nil || i < 1 {
+				return 0, fmt.Errorf("invalid %s step: %s",
+					f.name, step)
+			}
+			inc = i
+		}
+
+		// get range
+		first, last := f.min, f.max
+		if rng != "*" {
+			from, to, isRange := strings.Cut(rng, "-")
+			v, err := f.value(from)
+			if err != nil {
+				return 0, err
+			}
+			first = v
+			if isRange {
+				if last, err = f.value(to); err != nil {
+					return 0, err
+				}
+			} else if !hasStep {
+				last = first
+			}
+			if last < first {
+				return 0, fmt.Errorf("invalid %s range: %s",
+					f.name, rng)
+			}
+		}
+
+		// set matching values
+		for v := first; v <= last; v += inc {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Schedule is a parsed cron expression
+type Schedule struct {
+	second     uint64
+	minute     uint64
+	hour       uint64
+	day        uint64
+	month      uint64
+	weekday    uint64
+	anyDay     bool
+	anyWeekday bool
+	fixed      bool
+}
+
+// has returns whether bit v is set in bits
+func has(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
+
+// dayMatches returns whether the day of t matches the schedule; if both day
+// of month and day of week are restricted, either of them must match
+func (s *Schedule) dayMatches(t time.Time) bool {
+	day := has(s.day, t.Day())
+	weekday := has(s.weekday, int(t.Weekday()))
+	if s.anyDay || s.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+// Next returns the next time after t that matches the schedule or the zero
+// time if there is no such time within the next five years. Like in cron,
+// schedules with fixed minutes and hours run once at the end of wall clock
+// times skipped by daylight saving time changes and only once in repeated
+// wall clock times; other schedules move forward in absolute time and run in
+// repeated wall clock times again
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Second).Add(time.Second)
+	if s.fixed {
+		return s.nextFixed(t)
+	}
+	return s.next(t)
+}
+
+// nextFixed returns the next time from t on that matches the schedule with
+// fixed minutes and hours by searching wall clock times in the location of t
+func (s *Schedule) nextFixed(t time.Time) time.Time {
+	loc := t.Location()
+	w := wallTime(t)
+	for {
+		w = s.next(w)
+		if w.IsZero() {
+			return w
+		}
+		times := localTimes(w, loc)
+		switch {
+		case len(times) == 0:
+			// skipped wall clock time, run at the end of the gap
+			if end := gapEnd(w, loc); !end.Before(t) {
+				return end
+			}
+		case !times[0].Before(t):
+			// first occurrence of repeated wall clock times
+			return times[0]
+		}
+		w = w.Add(time.Second)
+	}
+}
+
+// next returns the next time from t on that matches the schedule; t only
+// moves forward in absolute time, so wall clock times skipped or repeated by
+// daylight saving time changes do not move it backwards
+func (s *Schedule) next(t time.Time) time.Time {
+	loc := t.Location()
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		y, m, d := t.Date()
+		next := t
+		switch {
+		case !has(s.month, int(m)):
+			next = time.Date(y, m+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			next = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+		case !has(s.hour, t.Hour()):
+			next = nextHour(t)
+		case !has(s.minute, t.Minute()):
+			next = nextMinute(t)
+		case !has(s.second, t.Second()):
+			next = t.Add(time.Second)
+		default:
+			return t
+		}
+		if !next.After(t) {
+			// midnight does not exist on this day
+			next = nextHour(t)
+		}
+		t = next
+	}
+	return time.Time{}
+}
+
+// wallTime returns the wall clock time of t in UTC, which has no daylight
+// saving time changes
+func wallTime(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), 0,
+		time.UTC)
+}
+
+// localTimes returns the times in loc with the wall clock time w in UTC in
+// ascending order; there is no time for skipped and two times for repeated
+// wall clock times
+func localTimes(w time.Time, loc *time.Location) []time.Time {
+	times := []time.Time{}
+	for _, d := range []time.Duration{-24 * time.Hour, 24 * time.Hour} {
+		_, offset := w.Add(d).In(loc).Zone()
+		t := w.Add(-time.Duration(offset) * time.Second).In(loc)
+		if !wallTime(t).Equal(w) ||
+			len(times) > 0 && times[0].Equal(t) {
+			continue
+		}
+		times = append(times, t)
+	}
+	if len(times) == 2 && times[1].Before(times[0]) {
+		times[0], times[1] = times[1], times[0]
+	}
+	return times
+}
+
+// gapEnd returns the time in loc at the end of the gap in wall clock times
+// that contains the skipped wall clock time w in UTC
+func gapEnd(w time.Time, loc *time.Location) time.Time {
+	_, offset := w.Add(-24 * time.Hour).In(loc).Zone()
+	start, _ := w.Add(-time.Duration(offset) * time.Second).In(loc).
+		ZoneBounds()
+	return start
+}
+
+// nextMinute returns the start of the minute after t
+func nextMinute(t time.Time) time.Time {
+	return t.Add(time.Duration(60-t.Second()) * time.Second)
+}
+
+// nextHour returns the start of the hour after t
+func nextHour(t time.Time) time.Time {
+	return nextMinute(t).Add(time.Duration(59-t.Minute()) * time.Minute)
+}
+
+// Parse parses the cron expression in spec; spec consists of the five fields
+// minute, hour, day of month, month and day of week, optionally preceded by
+// a seconds field, or is one of the descriptors @yearly, @annually,
+// @monthly, @weekly, @daily, @midnight and @hourly
+func Parse(spec string) (*Schedule, error) {
+	if d, ok := descriptors[strings.TrimSpace(spec)]; ok {
+		spec = d
+	}
+
+	// get fields, add seconds field if missing
+	f := strings.Fields(spec)
+	switch len(f) {
+	case 5:
+		f = append([]string{"0"}, f...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("invalid cron expression: %s", spec)
+	}
+
+	// parse fields
+	s := &Schedule{
+		anyDay:     f[3] == "*" || strings.HasPrefix(f[3], "*/"),
+		anyWeekday: f[5] == "*" || strings.HasPrefix(f[5], "*/"),
+		fixed: !strings.HasPrefix(f[1], "*") &&
+			!strings.HasPrefix(f[2], "*"),
+	}
+	for i, p := range []struct {
+		field *field
+		bits  *uint64
+	}{
+		{&seconds, &s.second},
+		{&minutes, &s.minute},
+		{&hours, &s.hour},
+		{&days, &s.day},
+		{&months, &s.month},
+		{&weekdays, &s.weekday},
+	} {
+		bits, err := p.field.parse(f[i])
+		if err != nil {
+			return nil, err
+		}
+		*p.bits = bits
+	}
+
+	// treat sunday as 0 and 7
+	if has(s.weekday, 7) {
+		s.weekday |= 1
+	}
+	return s, nil
+}
@@ -0,0 +1,212 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParse tests parsing cron expressions
+func TestParse(t *testing.T) {
+	// valid expressions
+	for _, spec := range []string{
+		"* * * * *",
+		"*/5 * * * *",
+		"15 9 * * mon-fri",
+		"0 0 1,15 * *",
+		"0 12 * jan-mar,dec sun",
+		"0 0 * * 7",
+		"30 */10 * * * *",
+		"0 5/15 8-18/2 * * *",
+		"@hourly",
+		"@daily",
+		"@yearly",
+	} {
+		if _, err := Parse(spec); err != nil {
+			t.Errorf("%q: got %v, want nil", spec, err)
+		}
+	}
+
+	// invalid expressions
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"* * * foo *",
+		"*/0 * * * *",
+		"10-5 * * * *",
+		"1,,2 * * * *",
+		"@weird",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("%q: got nil, want !nil", spec)
+		}
+	}
+}
+
+// TestNext tests getting the next time matching a cron expression
+func TestNext(t *testing.T) {
+	date := func(s string) time.Time {
+		d, err := time.Parse(time.DateTime, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	for _, test := range []struct {
+		spec string
+		from string
+		want string
+	}{
+		// every minute
+		{"* * * * *", "2024-01-01 10:00:00", "2024-01-01 10:01:00"},
+		{"* * * * *", "2024-01-01 10:00:30", "2024-01-01 10:01:00"},
+
+		// every 5 minutes
+		{"*/5 * * * *", "2024-01-01 10:03:00", "2024-01-01 10:05:00"},
+		{"*/5 * * * *", "2024-01-01 10:55:00", "2024-01-01 11:00:00"},
+
+		// every weekday at 09:15, 2024-01-05 is a friday
+		{"15 9 * * mon-fri", "2024-01-05 09:15:00",
+			"2024-01-08 09:15:00"},
+		{"15 9 * * mon-fri", "2024-01-05 09:14:59",
+			"2024-01-05 09:15:00"},
+
+		// sunday as 0 and 7, 2024-01-07 is a sunday
+		{"0 0 * * 0", "2024-01-01 00:00:00", "2024-01-07 00:00:00"},
+		{"0 0 * * 7", "2024-01-01 00:00:00", "2024-01-07 00:00:00"},
+
+		// day of month or day of week
+		{"0 0 15 * fri", "2024-01-01 00:00:00", "2024-01-05 00:00:00"},
+		{"0 0 15 * fri", "2024-01-12 00:00:00", "2024-01-15 00:00:00"},
+
+		// leap year
+		{"0 0 29 2 *", "2023-01-01 00:00:00", "2024-02-29 00:00:00"},
+
+		// seconds field
+		{"*/10 * * * * *", "2024-01-01 10:00:00", "2024-01-01 10:00:10"},
+		{"30 0 12 * * *", "2024-12-31 12:00:31", "2025-01-01 12:00:30"},
+
+		// descriptors
+		{"@hourly", "2024-01-01 10:59:59", "2024-01-01 11:00:00"},
+		{"@yearly", "2024-06-01 00:00:00", "2025-01-01 00:00:00"},
+
+		// no matching time
+		{"0 0 30 2 *", "2024-01-01 00:00:00", ""},
+	} {
+		s, err := Parse(test.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := time.Time{}
+		if test.want != "" {
+			want = date(test.want)
+		}
+		got := s.Next(date(test.from))
+		if !got.Equal(want) {
+			t.Errorf("%q from %s: got %v, want %v", test.spec,
+				test.from, got, want)
+		}
+	}
+}
+
+// TestNextDST tests getting the next times across daylight saving time
+// changes
+func TestNextDST(t *testing.T) {
+	date := func(loc *time.Location, s string) time.Time {
+		d, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d.In(loc)
+	}
+	load := func(name string) *time.Location {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			t.Skip(err)
+		}
+		return loc
+	}
+	ny := load("America/New_York")
+	kolkata := load("Asia/Kolkata")
+	havana := load("America/Havana")
+	for _, test := range []struct {
+		loc  *time.Location
+		spec string
+		from string
+		want string
+	}{
+		// 2024-03-10 02:00 EST is skipped to 03:00 EDT, skipped fixed
+		// times run once at the end of the gap
+		{ny, "0 2 * * *", "2024-03-10T01:45:00-05:00",
+			"2024-03-10T03:00:00-04:00"},
+		{ny, "30 2 * * *", "2024-03-10T01:45:00-05:00",
+			"2024-03-10T03:00:00-04:00"},
+		{ny, "0,30 2 * * *", "2024-03-10T01:45:00-05:00",
+			"2024-03-10T03:00:00-04:00"},
+		{ny, "0,30 2 * * *", "2024-03-10T03:00:00-04:00",
+			"2024-03-11T02:00:00-04:00"},
+		{ny, "30 1 * * *", "2024-03-10T01:45:00-05:00",
+			"2024-03-11T01:30:00-04:00"},
+		{ny, "0 3 * * *", "2024-03-10T01:45:00-05:00",
+			"2024-03-10T03:00:00-04:00"},
+		{ny, "* * * * *", "2024-03-10T01:59:00-05:00",
+			"2024-03-10T03:00:00-04:00"},
+
+		// 2024-11-03 02:00 EDT is repeated as 01:00 EST, repeated
+		// fixed times run only once
+		{ny, "30 1 * * *", "2024-11-03T01:15:00-04:00",
+			"2024-11-03T01:30:00-04:00"},
+		{ny, "30 1 * * *", "2024-11-03T01:45:00-04:00",
+			"2024-11-04T01:30:00-05:00"},
+		{ny, "30 1 * * *", "2024-11-03T01:15:00-05:00",
+			"2024-11-04T01:30:00-05:00"},
+		{ny, "*/30 * * * *", "2024-11-03T01:45:00-04:00",
+			"2024-11-03T01:00:00-05:00"},
+		{ny, "0 2 * * *", "2024-11-03T01:45:00-05:00",
+			"2024-11-03T02:00:00-05:00"},
+
+		// 2024-03-10 00:00 CST is skipped to 01:00 CDT
+		{havana, "0 0 10 * *", "2024-03-01T00:00:00-05:00",
+			"2024-03-10T01:00:00-04:00"},
+		{havana, "0 1 * * *", "2024-03-09T12:00:00-05:00",
+			"2024-03-10T01:00:00-04:00"},
+
+		// local hours with half hour offset
+		{kolkata, "0 * * * *", "2024-01-01T10:10:00+05:30",
+			"2024-01-01T11:00:00+05:30"},
+	} {
+		s, err := Parse(test.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		from := date(test.loc, test.from)
+		want := date(test.loc, test.want)
+		got := s.Next(from)
+		if !got.Equal(want) {
+			t.Errorf("%q from %v: got %v, want %v", test.spec, from,
+				got, want)
+		}
+	}
+
+	// next times are always after their start times
+	for _, spec := range []string{"0 2 * * *", "30 1 * * *", "0 0 * * *",
+		"*/7 * * * *"} {
+		s, err := Parse(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		from := date(ny, "2024-03-09T00:00:00-05:00")
+		for i := 0; i < 1000; i++ {
+			next := s.Next(from)
+			if !next.After(from) {
+				t.Fatalf("%q from %v: got %v", spec, from, next)
+			}
+			from = next
+		}
+	}
+}
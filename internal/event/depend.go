@@ -0,0 +1,173 @@
+package event
+
+import (
+	"fmt"
+	"log"
+	"sync"
+)
+
+const (
+	// ConditionSuccess triggers dependent events after successful runs
+	ConditionSuccess = "success"
+
+	// ConditionFailure triggers dependent events after failed runs
+	ConditionFailure = "failure"
+
+	// ConditionAlways triggers dependent events after all runs
+	ConditionAlways = "always"
+)
+
+var (
+	// outcomesMutex protects outcomes and outcomesChanged
+	outcomesMutex sync.Mutex
+
+	// outcomes stores whether the last run of each event succeeded
+	outcomes = make(map[string]bool)
+
+	// outcomesChanged is closed and replaced when outcomes change
+	outcomesChanged = make(chan struct{})
+)
+
+// setOutcome sets the outcome of the last run of the event with name and
+// notifies blocked events
+func setOutcome(name string, success bool) {
+	outcomesMutex.Lock()
+	defer outcomesMutex.Unlock()
+
+	outcomes[name] = success
+	close(outcomesChanged)
+	outcomesChanged = make(chan struct{})
+}
+
+// clearOutcome removes the outcome of the last run of the event with name, so
+// a new event with the same name does not inherit it
+func clearOutcome(name string) {
+	outcomesMutex.Lock()
+	defer outcomesMutex.Unlock()
+
+	delete(outcomes, name)
+}
+
+// dependenciesMet returns whether the last runs of all dependencies of the
+// event meet its condition; must be called while holding outcomesMutex
+func (e *Event) dependenciesMet() bool {
+	for _, d := range e.DependsOn {
+		success, ok := outcomes[d]
+		if !ok {
+			return false
+		}
+		switch e.Condition {
+		case ConditionAlways:
+		case ConditionFailure:
+			if success {
+				return false
+			}
+		default:
+			if !success {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// setBlocked sets the blocked state of the event
+func (e *Event) setBlocked(blocked bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.blocked = blocked
+}
+
+// IsBlocked returns whether the event is waiting for its dependencies
+func (e *Event) IsBlocked() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.blocked
+}
+
+// waitDependencies blocks until the dependencies of the event meet its
+// condition; it returns false if the event was stopped while waiting
+func (e *Event) waitDependencies() bool {
+	ctx := e.context()
+	for {
+		outcomesMutex.Lock()
+		met := e.dependenciesMet()
+		changed := outcomesChanged
+		outcomesMutex.Unlock()
+
+		if met {
+			e.setBlocked(false)
+			return true
+		}
+		if !e.IsBlocked() {
+			log.Printf("Event %s: waiting for dependencies: %v",
+				e.Name, e.DependsOn)
+			e.setBlocked(true)
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			e.setBlocked(false)
+			return false
+		}
+	}
+}
+
+// CheckDependencies checks if the dependencies of the event are valid, i.e.,
+// they are events in the event list and do not create a cycle with the
+// dependencies of the events in the event list
+func (e *Event) CheckDependencies() error {
+	events.Lock()
+	defer events.Unlock()
+
+	return events.checkDependencies(e)
+}
+
+// checkDependencies checks if the dependencies of event e are valid like
+// Event.CheckDependencies; must be called while holding the mutex
+func (l *eventList) checkDependencies(e *Event) error {
+	switch e.Condition {
+	case "", ConditionSuccess, ConditionFailure, ConditionAlways:
+	default:
+		return fmt.Errorf("invalid condition: %s", e.Condition)
+	}
+
+	// build dependency graph of all events including this event
+	graph := make(map[string][]string)
+	for name, evt := range l.m {
+		graph[name] = evt.DependsOn
+	}
+	graph[e.Name] = e.DependsOn
+
+	// search for a path from the dependencies back to this event
+	visited := make(map[string]bool)
+	var visit func(name string) bool
+	visit = func(name string) bool {
+		if name == e.Name {
+			return true
+		}
+		if visited[name] {
+			return false
+		}
+		visited[name] = true
+		for _, d := range graph[name] {
+			if visit(d) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, d := range e.DependsOn {
+		if _, ok := graph[d]; !ok {
+			return fmt.Errorf("unknown dependency: %s", d)
+		}
+		if visit(d) {
+			return fmt.Errorf("dependency cycle: %s depends on %s",
+				e.Name, d)
+		}
+	}
+	return nil
+}
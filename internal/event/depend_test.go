@@ -0,0 +1,195 @@
+package event
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hwipl/schedule-events/internal/command"
+)
+
+// TestCheckDependencies tests checking dependencies of events
+func TestCheckDependencies(t *testing.T) {
+	defer Flush()
+	Add(&Event{Name: "dep1"})
+	Add(&Event{Name: "dep2", DependsOn: []string{"dep1"}})
+	Add(&Event{Name: "dep3", DependsOn: []string{"dep2"}})
+
+	// valid dependencies
+	for _, e := range []*Event{
+		{Name: "new"},
+		{Name: "new", DependsOn: []string{"dep1"}},
+		{Name: "new", DependsOn: []string{"dep3"}},
+		{Name: "unknown", DependsOn: []string{"dep3"}},
+		{Name: "new", Condition: ConditionSuccess},
+		{Name: "new", Condition: ConditionFailure},
+		{Name: "new", Condition: ConditionAlways},
+	} {
+		if err := e.CheckDependencies(); err != nil {
+			t.Errorf("%s %v: got %v, want nil", e.Name, e.DependsOn,
+				err)
+		}
+	}
+
+	// invalid dependencies
+	for _, e := range []*Event{
+		{Name: "new", DependsOn: []string{"new"}},
+		{Name: "dep1", DependsOn: []string{"dep2"}},
+		{Name: "dep1", DependsOn: []string{"dep3"}},
+		{Name: "new", DependsOn: []string{"unknown"}},
+		{Name: "dep1", DependsOn: []string{"unknown"}},
+		{Name: "new", Condition: "sometimes"},
+	} {
+		if err := e.CheckDependencies(); err == nil {
+			t.Errorf("%s %v: got nil, want !nil", e.Name,
+				e.DependsOn)
+		}
+	}
+
+	// adding and replacing events with invalid dependencies
+	if err := Add(&Event{
+		Name:      "new",
+		DependsOn: []string{"unknown"},
+	}); err == nil || Get("new") != nil {
+		t.Error("added event with unknown dependency")
+	}
+	old := Get("dep1")
+	if err := Replace(old, &Event{
+		Name:      "dep1",
+		DependsOn: []string{"dep3"},
+	}); err == nil || Get("dep1") != old {
+		t.Error("replaced event with dependency cycle")
+	}
+}
+
+// TestClearOutcome tests clearing outcomes of removed and replaced events
+func TestClearOutcome(t *testing.T) {
+	defer Flush()
+	e1 := &Event{Name: "outcome"}
+	Add(e1)
+	setOutcome(e1.Name, true)
+
+	// replaced event does not inherit outcome
+	e2 := &Event{Name: "outcome"}
+	if err := Replace(e1, e2); err != nil {
+		t.Fatal(err)
+	}
+	outcomesMutex.Lock()
+	if _, ok := outcomes[e2.Name]; ok {
+		t.Error("outcome not cleared after replace")
+	}
+	outcomesMutex.Unlock()
+
+	// removed event does not leave an outcome
+	setOutcome(e2.Name, true)
+	Remove(e2)
+	outcomesMutex.Lock()
+	if _, ok := outcomes[e2.Name]; ok {
+		t.Error("outcome not cleared after remove")
+	}
+	outcomesMutex.Unlock()
+}
+
+// TestScheduleDependencies tests scheduling events with dependencies
+func TestScheduleDependencies(t *testing.T) {
+	command.Add(&command.Command{
+		Name:       "test-depend-ok",
+		Executable: "true",
+		Timeout:    10 * time.Second,
+	})
+	command.Add(&command.Command{
+		Name:       "test-depend-fail",
+		Executable: "false",
+		Timeout:    10 * time.Second,
+	})
+	t.Cleanup(func() {
+		for _, name := range []string{"setup1", "setup2", "setup3",
+			"load1", "load2", "load3", "diag1"} {
+			clearOutcome(name)
+		}
+	})
+	schedule := func(e *Event) chan struct{} {
+		done := make(chan struct{})
+		go func() {
+			e.Schedule()
+			close(done)
+		}()
+		return done
+	}
+	wait := func(done chan struct{}) {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("event not done")
+		}
+	}
+	waitBlocked := func(e *Event) {
+		deadline := time.Now().Add(5 * time.Second)
+		for !e.IsBlocked() {
+			if time.Now().After(deadline) {
+				t.Fatal("event not blocked")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// dependent event is blocked until its dependencies succeeded
+	setup1 := &Event{Name: "setup1", Command: "test-depend-ok"}
+	setup2 := &Event{Name: "setup2", Command: "test-depend-fail"}
+	e1 := &Event{
+		Name:      "load1",
+		Command:   "test-depend-ok",
+		DependsOn: []string{"setup1", "setup2"},
+	}
+	done := schedule(e1)
+	waitBlocked(e1)
+	setup1.Schedule()
+	setup2.Schedule()
+	if !e1.IsBlocked() || len(e1.Runs()) != 0 {
+		t.Error("event not blocked after failed dependency")
+	}
+	setup2.Command = "test-depend-ok"
+	setup2.Schedule()
+	wait(done)
+	if e1.IsBlocked() || len(e1.Runs()) != 1 {
+		t.Error("event not run after successful dependencies")
+	}
+
+	// dependent event with failure condition
+	e2 := &Event{
+		Name:      "diag1",
+		Command:   "test-depend-ok",
+		DependsOn: []string{"setup3"},
+		Condition: ConditionFailure,
+	}
+	done = schedule(e2)
+	setup3 := &Event{Name: "setup3", Command: "test-depend-fail"}
+	setup3.Schedule()
+	wait(done)
+	if len(e2.Runs()) != 1 {
+		t.Error("event not run after failed dependency")
+	}
+
+	// dependencies already met
+	e3 := &Event{
+		Name:      "load2",
+		Command:   "test-depend-ok",
+		DependsOn: []string{"setup1"},
+		Condition: ConditionAlways,
+	}
+	e3.Schedule()
+	if len(e3.Runs()) != 1 {
+		t.Error("event not run after met dependency")
+	}
+
+	// stop blocked event
+	e4 := NewEvent()
+	e4.Name = "load3"
+	e4.DependsOn = []string{"does not exist"}
+	done = schedule(e4)
+	waitBlocked(e4)
+	e4.Stop()
+	wait(done)
+	if e4.IsBlocked() || len(e4.Runs()) != 0 {
+		t.Error("stopped event not unblocked")
+	}
+}
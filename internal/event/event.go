@@ -1,41 +1,157 @@
 package event
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
-	"math/rand"
 	"os"
+	"slices"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/hwipl/schedule-events/internal/clock"
 	"github.com/hwipl/schedule-events/internal/command"
+	"github.com/hwipl/schedule-events/internal/cron"
+)
+
+const (
+	// ModeFixedDelay schedules periodic runs of an event after the
+	// previous run finished
+	ModeFixedDelay = "fixed-delay"
+
+	// ModeFixedRate schedules periodic runs of an event relative to the
+	// scheduled time of the previous run
+	ModeFixedRate = "fixed-rate"
+
+	// OverlapSkip skips runs of a fixed rate event while a previous run
+	// is still running
+	OverlapSkip = "skip"
+
+	// OverlapQueue queues runs of a fixed rate event while a previous run
+	// is still running and starts them after it finished
+	OverlapQueue = "queue"
+
+	// OverlapAllow starts runs of a fixed rate event concurrently to
+	// previous runs up to the maximum concurrency of the event
+	OverlapAllow = "allow"
+
+	// DefaultMaxQueued is the maximum number of queued runs of a fixed
+	// rate event with overlap policy queue without maximum concurrency
+	DefaultMaxQueued = 10
+
+	// StateScheduled is the state of an event waiting for its next run
+	StateScheduled = "scheduled"
+
+	// StateBlocked is the state of an event waiting for its dependencies
+	StateBlocked = "blocked"
+
+	// StatePaused is the state of a paused event
+	StatePaused = "paused"
+
+	// StateRunning is the state of an event with a running command
+	StateRunning = "running"
+
+	// StateDone is the state of an event without further runs
+	StateDone = "done"
+
+	// StateFailed is the state of an event without further runs whose
+	// last run failed
+	StateFailed = "failed"
 )
 
 var (
+	// ErrExists is the error when adding an event with the name of an
+	// existing event
+	ErrExists = errors.New("event already exists")
+
+	// ErrNotFound is the error when replacing an event that is not in the
+	// event list
+	ErrNotFound = errors.New("event not found")
+
 	// events stores a list of all events
 	events = newEventList()
+
+	// HistorySize is the number of runs kept in the run history of each
+	// event
+	HistorySize = 10
+
+	// FinishedSize is the number of finished events kept in the event
+	// list; older finished events are removed
+	FinishedSize = 100
+
+	// lastRunID is the id of the last run of all events
+	lastRunID atomic.Uint64
+
+	// clockMutex protects eventClock and eventRand
+	clockMutex sync.RWMutex
+
+	// eventClock is the clock used for scheduling and running events
+	eventClock clock.Clock = clock.Real{}
+
+	// eventRand is the random source for random wait times of events
+	eventRand = clock.NewRand(time.Now().UnixNano())
 )
 
-// eventList is a list of events identified by their name
+// eventList is a list of events identified by their name; finished events
+// are kept in the list in the order they finished
 type eventList struct {
 	sync.Mutex
-	m map[string]*Event
+	m        map[string]*Event
+	finished []*Event
 }
 
-// Add adds event to the event list
-func (e *eventList) Add(event *Event) bool {
+// Add adds event to the event list; it returns ErrExists if an event with
+// the same name exists and a FieldError if its dependencies are invalid.
+// Finished events with the same name are replaced
+func (e *eventList) Add(event *Event) error {
 	e.Lock()
 	defer e.Unlock()
 
-	// do not overwrite existing entry
-	if _, ok := e.m[event.Name]; ok {
-		return false
+	// do not overwrite existing entry unless it is finished
+	if old, ok := e.m[event.Name]; ok && !old.isFinished() {
+		return ErrExists
+	}
+	if err := e.checkDependencies(event); err != nil {
+		return fieldError("DependsOn", "%v", err)
 	}
 
 	// save new event
+	e.unfinish(event.Name)
 	e.m[event.Name] = event
-	return true
+	return nil
+}
+
+// finish marks event in the event list as finished and removes the oldest
+// finished events exceeding the maximum number of finished events; it
+// returns the removed events
+func (e *eventList) finish(event *Event) []*Event {
+	e.Lock()
+	defer e.Unlock()
+
+	if e.m[event.Name] != event {
+		return nil
+	}
+	e.finished = append(e.finished, event)
+	removed := []*Event{}
+	for len(e.finished) > max(FinishedSize, 0) {
+		evt := e.finished[0]
+		e.finished = e.finished[1:]
+		delete(e.m, evt.Name)
+		removed = append(removed, evt)
+	}
+	return removed
+}
+
+// unfinish removes the event with name from the finished events; must be
+// called while holding the mutex
+func (e *eventList) unfinish(name string) {
+	e.finished = slices.DeleteFunc(e.finished, func(evt *Event) bool {
+		return evt.Name == name
+	})
 }
 
 // Remove removes event from the event list and returns the removed event
@@ -49,10 +165,29 @@ func (e *eventList) Remove(event *Event) *Event {
 		return nil
 	}
 	delete(e.m, event.Name)
+	e.unfinish(event.Name)
 
 	return evt
 }
 
+// Replace replaces the event old with event in the event list; it returns
+// ErrNotFound if old is not in the event list and a FieldError if the
+// dependencies of event are invalid
+func (e *eventList) Replace(old, event *Event) error {
+	e.Lock()
+	defer e.Unlock()
+
+	if old.Name != event.Name || e.m[old.Name] != old {
+		return ErrNotFound
+	}
+	if err := e.checkDependencies(event); err != nil {
+		return fieldError("DependsOn", "%v", err)
+	}
+	e.unfinish(event.Name)
+	e.m[event.Name] = event
+	return nil
+}
+
 // Get returns the event identified by its name
 func (e *eventList) Get(name string) *Event {
 	e.Lock()
@@ -82,6 +217,7 @@ func (e *eventList) Flush() []*Event {
 
 	evts := e.list()
 	e.m = make(map[string]*Event)
+	e.finished = nil
 	return evts
 }
 
@@ -100,38 +236,346 @@ func newEventList() *eventList {
 	}
 }
 
+// SetClock sets the clock used for scheduling and running events
+func SetClock(c clock.Clock) {
+	clockMutex.Lock()
+	defer clockMutex.Unlock()
+
+	eventClock = c
+}
+
+// getClock returns the clock used for scheduling and running events
+func getClock() clock.Clock {
+	clockMutex.RLock()
+	defer clockMutex.RUnlock()
+
+	return eventClock
+}
+
+// SetRand sets the random source for random wait times of events
+func SetRand(r clock.Rand) {
+	clockMutex.Lock()
+	defer clockMutex.Unlock()
+
+	eventRand = r
+}
+
+// getRand returns the random source for random wait times of events
+func getRand() clock.Rand {
+	clockMutex.RLock()
+	defer clockMutex.RUnlock()
+
+	return eventRand
+}
+
+// Run is a record of a command run of an event
+type Run struct {
+	ID           uint64
+	Number       uint64
+	Scheduled    time.Time
+	Attempt      int
+	Start        time.Time
+	End          time.Time
+	Error        string
+	QueueDelay   time.Duration
+	TriggerEvent string
+	TriggerRun   uint64
+	command.Result
+}
+
+// environ returns the environment variables with the context of the run of
+// event e
+func (r *Run) environ(e *Event) []string {
+	return []string{
+		"SCHEDULE_EVENT_NAME=" + e.Name,
+		fmt.Sprintf("SCHEDULE_RUN_ID=%d", r.ID),
+		fmt.Sprintf("SCHEDULE_RUN_NUMBER=%d", r.Number),
+		"SCHEDULE_SCHEDULED_TIME=" + r.Scheduled.Format(time.RFC3339),
+	}
+}
+
 // Event is an event that can be scheduled
 type Event struct {
-	Name      string
-	Command   string
-	StartDate time.Time
-	StopDate  time.Time
-	Timeout   time.Duration
-	Periodic  bool
-	WaitMin   time.Duration
-	WaitMax   time.Duration
-	done      bool
-	stop      chan struct{}
+	Name          string
+	Command       string
+	Parameters    map[string]string
+	StartDate     time.Time
+	StopDate      time.Time
+	Timeout       time.Duration
+	Periodic      bool
+	Cron          string
+	WaitMin       time.Duration
+	WaitMax       time.Duration
+	Retry         *Retry
+	Paused        bool
+	Mode          string
+	Overlap       string
+	MaxConcurrent int
+	DependsOn     []string
+	Condition     string
+	Priority      int
+	OnSuccess     *Hook
+	OnFailure     *Hook
+	OnTimeout     *Hook
+	restored      bool
+	running       sync.WaitGroup
+	ctx           context.Context
+	cancel        context.CancelFunc
+	runCtx        context.Context
+	runCancel     context.CancelFunc
+
+	mutex     sync.Mutex
+	numRuns   uint64
+	retries   uint64
+	runs      []*Run
+	active    int
+	queue     []time.Time
+	blocked   bool
+	next      time.Time
+	inflight  int
+	failures  uint64
+	finished  bool
+	successor *Event
+	resume    func()
 }
 
 // init initializes the event
 func (e *Event) init() {
-	e.stop = make(chan struct{})
+	e.runCtx, e.runCancel = context.WithCancel(context.Background())
+	e.ctx, e.cancel = context.WithCancel(e.runCtx)
+}
+
+// context returns the context of the event that is done when the event is
+// stopped or retired; it initializes the event if necessary
+func (e *Event) context() context.Context {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.ctx == nil {
+		e.init()
+	}
+	return e.ctx
+}
+
+// runContext returns the context of the running commands of the event that is
+// done when the event is stopped; it initializes the event if necessary
+func (e *Event) runContext() context.Context {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.ctx == nil {
+		e.init()
+	}
+	return e.runCtx
+}
+
+// newRun returns a new run record of the event scheduled at time scheduled
+// for attempt number attempt
+func (e *Event) newRun(scheduled time.Time, attempt int) *Run {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.numRuns++
+	e.inflight++
+	if attempt > 1 {
+		e.retries++
+	}
+	return &Run{
+		ID:        lastRunID.Add(1),
+		Number:    e.numRuns,
+		Scheduled: scheduled,
+		Attempt:   attempt,
+	}
+}
+
+// addRun adds the finished run to the event's run history and removes the
+// oldest runs exceeding the history size; runs finished after the event was
+// replaced are also added to the run history of the replacing event
+func (e *Event) addRun(run *Run) {
+	e.mutex.Lock()
+	e.inflight--
+	e.recordRun(run)
+	successor := e.successor
+	e.mutex.Unlock()
+
+	for successor != nil {
+		successor.mutex.Lock()
+		successor.recordRun(run)
+		next := successor.successor
+		successor.mutex.Unlock()
+		successor = next
+	}
+}
+
+// recordRun adds the finished run to the event's run history and removes the
+// oldest runs exceeding the history size; must be called while holding the
+// mutex
+func (e *Event) recordRun(run *Run) {
+	if run.Error != "" && !run.Canceled {
+		e.failures++
+	}
+	e.runs = append(e.runs, run)
+	if n := len(e.runs) - HistorySize; n > 0 {
+		e.runs = e.runs[n:]
+	}
 }
 
-// Run executes the event's command once
-func (e *Event) Run() {
-	log.Printf("Event %s: running command: %s", e.Name, e.Command)
+// attempt executes the event's command once and returns the record of the
+// run; scheduled is the time the run was scheduled for, n is the number of
+// the attempt, t is the run that triggered this run as a hook or nil, delay
+// is the time the run waited in the run queue
+func (e *Event) attempt(scheduled time.Time, n int, t *trigger,
+	delay time.Duration) *Run {
+	run := e.newRun(scheduled, n)
+	run.QueueDelay = delay
+	if t != nil {
+		run.TriggerEvent = t.event
+		run.TriggerRun = t.run.ID
+	}
+	run.Start = getClock().Now()
+	defer func() {
+		run.End = getClock().Now()
+		e.addRun(run)
+	}()
+
+	log.Printf("Event %s: running command: %s, attempt %d", e.Name,
+		e.Command, n)
 	c := command.Get(e.Command)
 	if c == nil {
 		log.Printf("Event %s: command not found: %s", e.Name,
 			e.Command)
-		return
+		run.ExitCode = -1
+		run.Error = "command not found"
+		return run
+	}
+	if err := e.context().Err(); err != nil {
+		// event stopped before the command started
+		run.Canceled = true
+		run.Error = err.Error()
+		return run
 	}
-	err := c.Run()
+	result, err := c.Run(e.runContext(), &command.Options{
+		Timeout:    e.Timeout,
+		Env:        append(run.environ(e), t.environ()...),
+		Parameters: e.Parameters,
+	})
+	run.Result = *result
 	if err != nil {
 		log.Printf("Event %s: command error: %s", e.Name, err)
+		run.Error = err.Error()
+	}
+	return run
+}
+
+// Run executes the event's command and retries failed runs according to the
+// event's retry policy; it returns the record of the last run, scheduled is
+// the time the run was scheduled for
+func (e *Event) Run(scheduled time.Time) *Run {
+	return e.run(scheduled, nil, 0)
+}
+
+// run executes the event's command like Run; t is the run that triggered
+// this run as a hook or nil, delay is the time the run waited in the run
+// queue. Retries and hooks run in workers of the default scheduler
+func (e *Event) run(scheduled time.Time, t *trigger,
+	delay time.Duration) *Run {
+	done := make(chan *Run, 1)
+	e.execute(getScheduler(), scheduled, t, delay, func(run *Run) {
+		done <- run
+	})
+	return <-done
+}
+
+// execute executes the event's command and retries failed runs according to
+// the event's retry policy without blocking a worker of scheduler s between
+// the attempts; done is called with the record of the last run after the
+// hooks of the event. Hooks of the event only run if t is nil, so hooks
+// cannot trigger each other in a loop
+func (e *Event) execute(s *Scheduler, scheduled time.Time, t *trigger,
+	delay time.Duration, done func(*Run)) {
+	e.retry(s, e.attempt(scheduled, 1, t, delay), t, done)
+}
+
+// retry schedules the next attempt of the failed run with scheduler s if the
+// event's retry policy allows it, otherwise it completes the run
+func (e *Event) retry(s *Scheduler, run *Run, t *trigger, done func(*Run)) {
+	n := run.Attempt + 1
+	if !e.Retry.retryable(run, n) {
+		e.complete(s, run, t, done)
+		return
+	}
+	delay := e.Retry.delay(n - 1)
+	log.Printf("Event %s: retrying run %d in %v, attempt %d of %d",
+		e.Name, run.ID, delay, n, e.Retry.MaxAttempts)
+	s.after(e, delay, func(delay time.Duration) {
+		if e.context().Err() != nil {
+			e.complete(s, run, t, done)
+			return
+		}
+		e.retry(s, e.attempt(getClock().Now(), n, t, delay), t, done)
+	}, func() {
+		e.complete(s, run, t, done)
+	})
+}
+
+// complete sets the outcome of the last run of the event and runs its hooks
+// with scheduler s before calling done
+func (e *Event) complete(s *Scheduler, run *Run, t *trigger,
+	done func(*Run)) {
+	if run.Canceled {
+		done(run)
+		return
+	}
+	setOutcome(e.Name, run.Error == "")
+	if t != nil {
+		done(run)
+		return
+	}
+	e.runHooks(s, run, func() {
+		done(run)
+	})
+}
+
+// Retries returns the number of retried runs of the event
+func (e *Event) Retries() uint64 {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.retries
+}
+
+// Runs returns the event's run history
+func (e *Event) Runs() []*Run {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	runs := make([]*Run, len(e.runs))
+	copy(runs, e.runs)
+	return runs
+}
+
+// GetRun returns the run identified by its id from the event's run history
+func (e *Event) GetRun(id uint64) *Run {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for _, r := range e.runs {
+		if r.ID == id {
+			return r
+		}
+	}
+	return nil
+}
+
+// LastRun returns the last run in the event's run history
+func (e *Event) LastRun() *Run {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if len(e.runs) == 0 {
+		return nil
 	}
+	return e.runs[len(e.runs)-1]
 }
 
 // nextWait returns the next wait duration for the event
@@ -155,56 +599,285 @@ func (e *Event) nextWait() time.Duration {
 
 	// get next wait time, random in milliseconds granularity
 	diff := max.Milliseconds() - min.Milliseconds()
-	s := rand.NewSource(time.Now().UnixNano())
-	r := rand.New(s)
-	t := min.Milliseconds() + r.Int63n(diff)
+	t := min.Milliseconds() + getRand().Int63n(diff)
 
 	return time.Duration(t) * time.Millisecond
 }
 
-// scheduleWait schedules the event after the wait duration
-func (e *Event) scheduleWait(wait time.Duration) {
-	if wait < 0 {
-		wait = 0
+// maxQueued returns the maximum number of queued runs of the fixed rate
+// event; it is the maximum concurrency of the event if set
+func (e *Event) maxQueued() int {
+	if e.MaxConcurrent > 0 {
+		return e.MaxConcurrent
 	}
-	if !e.StopDate.IsZero() && time.Now().Add(wait).After(e.StopDate) {
-		e.done = true
-		return
+	return DefaultMaxQueued
+}
+
+// fire runs the fixed rate event scheduled at time scheduled with scheduler
+// s according to its overlap policy
+func (e *Event) fire(s *Scheduler, scheduled time.Time) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	switch {
+	case e.active == 0:
+		e.start(s, scheduled)
+	case e.Overlap == OverlapAllow &&
+		(e.MaxConcurrent == 0 || e.active < e.MaxConcurrent):
+		e.start(s, scheduled)
+	case e.Overlap == OverlapQueue && len(e.queue) < e.maxQueued():
+		log.Printf("Event %s: previous run still running, "+
+			"queueing run", e.Name)
+		e.queue = append(e.queue, scheduled)
+	case e.Overlap == OverlapQueue:
+		log.Printf("Event %s: run queue full, skipping run", e.Name)
+	default:
+		log.Printf("Event %s: previous run still running, "+
+			"skipping run", e.Name)
 	}
-	timer := time.NewTimer(wait)
-	select {
-	case <-timer.C:
-		e.Run()
-	case <-e.stop:
-		if !timer.Stop() {
-			<-timer.C
+}
+
+// start starts the run of the event scheduled at time scheduled and
+// afterwards the queued runs in workers of scheduler s; must be called while
+// holding the mutex
+func (e *Event) start(s *Scheduler, scheduled time.Time) {
+	e.active++
+	e.running.Add(1)
+	s.submit(e.Priority, func(delay time.Duration) {
+		e.runQueued(s, scheduled, delay)
+	})
+}
+
+// runQueued runs the event scheduled at time scheduled in a worker of
+// scheduler s and submits the next queued run afterwards
+func (e *Event) runQueued(s *Scheduler, scheduled time.Time,
+	delay time.Duration) {
+	next := func(*Run) {
+		// get next queued run
+		e.mutex.Lock()
+		if len(e.queue) == 0 || e.ctx.Err() != nil {
+			e.queue = nil
+			e.active--
+			e.mutex.Unlock()
+			e.running.Done()
+			return
 		}
-		e.done = true
+		scheduled := e.queue[0]
+		e.queue = e.queue[1:]
+		e.mutex.Unlock()
+
+		s.submit(e.Priority, func(delay time.Duration) {
+			e.runQueued(s, scheduled, delay)
+		})
+	}
+	if e.context().Err() != nil {
+		next(nil)
+		return
 	}
+	e.execute(s, scheduled, nil, delay, next)
 }
 
-// Schedule schedules the event for execution
+// Start schedules the event for execution with the default scheduler without
+// blocking; done is called when the event is done
+func (e *Event) Start(done func()) {
+	getScheduler().Schedule(e, done)
+}
+
+// Schedule schedules the event for execution with the default scheduler and
+// blocks until the event is done
 func (e *Event) Schedule() {
-	log.Println("Scheduling event:", e.Name)
+	done := make(chan struct{})
+	e.Start(func() {
+		close(done)
+	})
+	<-done
+}
 
-	// schedule first execution
-	wait := e.StartDate.Sub(time.Now())
-	e.scheduleWait(wait)
+// setNext sets the time of the next run of the event, zero if there is no
+// next run
+func (e *Event) setNext(next time.Time) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
 
-	// schedule periodic executions
-	for e.Periodic && !e.done {
-		wait = e.nextWait()
-		e.scheduleWait(wait)
+	e.next = next
+}
+
+// nextRun returns the time of the next run of the event, zero if there is no
+// next run
+func (e *Event) nextRun() time.Time {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.next
+}
+
+// setFinished sets the event to finished after its last run
+func (e *Event) setFinished() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.finished = true
+}
+
+// isFinished returns whether the event is finished
+func (e *Event) isFinished() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.finished
+}
+
+// state returns the lifecycle state of the event; must be called while
+// holding the mutex
+func (e *Event) state() string {
+	switch {
+	case e.finished:
+		if len(e.runs) > 0 && e.runs[len(e.runs)-1].Error != "" {
+			return StateFailed
+		}
+		return StateDone
+	case e.inflight > 0:
+		return StateRunning
+	case e.Paused:
+		return StatePaused
+	case e.blocked:
+		return StateBlocked
+	default:
+		return StateScheduled
 	}
+}
+
+// State returns the lifecycle state of the event
+func (e *Event) State() string {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
 
-	// event done, clean up
-	log.Println("Event done:", e.Name)
-	Remove(e)
+	return e.state()
 }
 
-// Stop stops a scheduled event
+// Stop stops a scheduled event and its running command
 func (e *Event) Stop() {
-	e.stop <- struct{}{}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.ctx == nil {
+		e.init()
+	}
+	e.runCancel()
+}
+
+// Retire stops a scheduled event that was replaced without stopping its
+// running command; runs finishing afterwards are added to the run history of
+// the replacing event
+func (e *Event) Retire() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.ctx == nil {
+		e.init()
+	}
+	e.cancel()
+}
+
+// setSuccessor sets the event that replaced the event
+func (e *Event) setSuccessor(successor *Event) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.successor = successor
+}
+
+// setPaused sets the paused state of the event and saves the state file
+func (e *Event) setPaused(paused bool) {
+	e.mutex.Lock()
+	e.Paused = paused
+	e.mutex.Unlock()
+
+	saveState()
+}
+
+// Pause pauses the event; scheduled runs are skipped while it is paused
+func (e *Event) Pause() {
+	log.Println("Pausing event:", e.Name)
+	e.setPaused(true)
+}
+
+// Resume resumes the paused event; a one-shot event that waited for its
+// resume runs immediately
+func (e *Event) Resume() {
+	log.Println("Resuming event:", e.Name)
+	e.setPaused(false)
+	if resume := e.unpark(); resume != nil {
+		resume()
+	}
+}
+
+// park lets the paused one-shot event due at time when wait for its resume;
+// resume is called when the event is resumed. It returns false if the event
+// is not paused or already stopped
+func (e *Event) park(when time.Time, resume func()) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if !e.Paused || e.ctx != nil && e.ctx.Err() != nil {
+		return false
+	}
+	e.next = when
+	e.resume = resume
+	return true
+}
+
+// unpark returns the resume function of the parked event and removes it, nil
+// if the event is not parked
+func (e *Event) unpark() func() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	resume := e.resume
+	e.resume = nil
+	return resume
+}
+
+// IsPaused returns whether the event is paused
+func (e *Event) IsPaused() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.Paused
+}
+
+// MarshalJSON returns the event as json while holding its mutex; the json
+// contains the read-only fields Blocked, State, NextRun, LastRun, RunCount,
+// FailureCount and RetryCount
+func (e *Event) MarshalJSON() ([]byte, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	var lastRun time.Time
+	if len(e.runs) > 0 {
+		lastRun = e.runs[len(e.runs)-1].Start
+	}
+
+	type event Event
+	return json.Marshal(&struct {
+		*event
+		Blocked      bool
+		State        string
+		NextRun      time.Time
+		LastRun      time.Time
+		RunCount     uint64
+		FailureCount uint64
+		RetryCount   uint64
+	}{
+		event:        (*event)(e),
+		Blocked:      e.blocked,
+		State:        e.state(),
+		NextRun:      e.next,
+		LastRun:      lastRun,
+		RunCount:     e.numRuns,
+		FailureCount: e.failures,
+		RetryCount:   e.retries,
+	})
 }
 
 // JSON returns the event as json
@@ -216,6 +889,134 @@ func (e *Event) JSON() ([]byte, error) {
 	return b, nil
 }
 
+// FieldError is a validation error of a field of an event
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// Error returns the field error as string
+func (f *FieldError) Error() string {
+	return fmt.Sprintf("invalid event field %s: %s", f.Field, f.Message)
+}
+
+// fieldError returns a new field error of field with a formatted message
+func fieldError(field, format string, a ...any) error {
+	return &FieldError{Field: field, Message: fmt.Sprintf(format, a...)}
+}
+
+// Check checks if the event is valid; invalid fields are reported with a
+// FieldError
+func (e *Event) Check() error {
+	switch {
+	case len(e.Name) > 256:
+		return fieldError("Name", "Name longer than 256 characters")
+	case len(e.Command) > 256:
+		return fieldError("Command",
+			"Command longer than 256 characters")
+	case command.Get(e.Command) == nil:
+		return fieldError("Command", "command not found: %s", e.Command)
+	case !e.StopDate.IsZero() && e.StopDate.Before(e.StartDate):
+		return fieldError("StopDate", "StopDate < StartDate")
+	case !e.StopDate.IsZero() && e.StopDate.Before(getClock().Now()):
+		return fieldError("StopDate", "StopDate in the past")
+	case e.Timeout < 0:
+		return fieldError("Timeout", "Timeout < 0")
+	case e.WaitMin < 0:
+		return fieldError("WaitMin", "WaitMin < 0")
+	case e.WaitMax < 0:
+		return fieldError("WaitMax", "WaitMax < 0")
+	case e.WaitMax != 0 && e.WaitMax < e.WaitMin:
+		return fieldError("WaitMax", "WaitMax < WaitMin")
+	case e.Periodic && e.WaitMin == 0:
+		return fieldError("WaitMin", "WaitMin == 0 in periodic event")
+	case e.Retry != nil && !e.Retry.Valid():
+		return fieldError("Retry", "invalid retry policy")
+	case e.Mode != "" &&
+		e.Mode != ModeFixedDelay &&
+		e.Mode != ModeFixedRate:
+		return fieldError("Mode", "invalid mode: %s", e.Mode)
+	case e.Overlap != "" &&
+		e.Overlap != OverlapSkip &&
+		e.Overlap != OverlapQueue &&
+		e.Overlap != OverlapAllow:
+		return fieldError("Overlap", "invalid overlap policy: %s",
+			e.Overlap)
+	case e.MaxConcurrent < 0:
+		return fieldError("MaxConcurrent", "MaxConcurrent < 0")
+	}
+
+	// check if command parameters are valid
+	cmd := command.Get(e.Command)
+	if err := cmd.CheckParameters(e.Parameters); err != nil {
+		return fieldError("Parameters", "%v", err)
+	}
+
+	// check if dependencies are valid
+	if err := e.CheckDependencies(); err != nil {
+		return fieldError("DependsOn", "%v", err)
+	}
+
+	// check if hooks are valid
+	if err := e.CheckHooks(); err != nil {
+		return err
+	}
+
+	// check if cron expression is valid
+	if e.Cron != "" {
+		if _, err := cron.Parse(e.Cron); err != nil {
+			return fieldError("Cron", "%v", err)
+		}
+		if e.Periodic {
+			return fieldError("Cron", "Cron in periodic event")
+		}
+	}
+	return nil
+}
+
+// Patch returns a copy of the event with the fields in the json object b
+// applied to it
+func (e *Event) Patch(b []byte) (*Event, error) {
+	// get fields of the event
+	old, err := e.JSON()
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(old, &fields); err != nil {
+		return nil, err
+	}
+
+	// apply fields in b
+	patch := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(b, &patch); err != nil {
+		return nil, err
+	}
+	for k, v := range patch {
+		fields[k] = v
+	}
+	b, err = json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	return NewFromJSON(b)
+}
+
+// inherit copies the run counters and the run history of the event old to
+// the event
+func (e *Event) inherit(old *Event) {
+	old.mutex.Lock()
+	defer old.mutex.Unlock()
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.numRuns = old.numRuns
+	e.retries = old.retries
+	e.failures = old.failures
+	e.runs = append([]*Run{}, old.runs...)
+	e.restored = true
+}
+
 // NewFromJSON parses an event from json
 func NewFromJSON(b []byte) (*Event, error) {
 	e := NewEvent()
@@ -233,14 +1034,50 @@ func NewEvent() *Event {
 	return e
 }
 
-// Add adds event to the event list
-func Add(event *Event) bool {
-	return events.Add(event)
+// Add adds event to the event list; it returns ErrExists if an event with
+// the same name exists and a FieldError if its dependencies are invalid
+func Add(event *Event) error {
+	if err := events.Add(event); err != nil {
+		return err
+	}
+	clearOutcome(event.Name)
+	saveState()
+	return nil
 }
 
 // Remove removes event from the event list
 func Remove(event *Event) *Event {
-	return events.Remove(event)
+	evt := events.Remove(event)
+	if evt != nil {
+		clearOutcome(evt.Name)
+		saveState()
+	}
+	return evt
+}
+
+// Replace replaces the event old with event in the event list; event keeps
+// the run counters and run history of old and periodic executions resume
+// without an immediate run. Runs of old finishing afterwards are added to the
+// run history of event. It returns ErrNotFound if old is not in the event
+// list and a FieldError if the dependencies of event are invalid
+func Replace(old, event *Event) error {
+	event.inherit(old)
+	if err := events.Replace(old, event); err != nil {
+		return err
+	}
+	old.setSuccessor(event)
+	clearOutcome(event.Name)
+	saveState()
+	return nil
+}
+
+// finish keeps the finished event in the event list and removes the oldest
+// finished events exceeding the maximum number of finished events
+func finish(event *Event) {
+	for _, e := range events.finish(event) {
+		clearOutcome(e.Name)
+	}
+	saveState()
 }
 
 // Get returns the event identified by name
@@ -250,7 +1087,12 @@ func Get(name string) *Event {
 
 // Flush removes all events in the event list and returns the removed events
 func Flush() []*Event {
-	return events.Flush()
+	evts := events.Flush()
+	for _, e := range evts {
+		clearOutcome(e.Name)
+	}
+	saveState()
+	return evts
 }
 
 // List returns all events in the event list
@@ -276,7 +1118,38 @@ func EventsFromJSON(path string) error {
 	// add events to event list
 	for _, e := range evts {
 		e.init()
-		Add(e)
 	}
+	addEvents(evts)
 	return nil
 }
+
+// addEvents adds the events in evts to the event list; events are added
+// after the events they depend on, events that cannot be added are logged
+func addEvents(evts []*Event) {
+	for len(evts) > 0 {
+		// add all events whose dependencies are in the event list
+		rest := []*Event{}
+		errs := []error{}
+		for _, e := range evts {
+			err := Add(e)
+			var f *FieldError
+			if errors.As(err, &f) && f.Field == "DependsOn" {
+				rest = append(rest, e)
+				errs = append(errs, err)
+				continue
+			}
+			if err != nil {
+				log.Printf("Event %s: %v", e.Name, err)
+			}
+		}
+
+		// stop if the remaining events cannot be added
+		if len(rest) == len(evts) {
+			for i, e := range rest {
+				log.Printf("Event %s: %v", e.Name, errs[i])
+			}
+			return
+		}
+		evts = rest
+	}
+}
@@ -1,9 +1,16 @@
 package event
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"reflect"
+	"sort"
 	"testing"
 	"time"
+
+	"github.com/hwipl/schedule-events/internal/clock"
+	"github.com/hwipl/schedule-events/internal/command"
 )
 
 // TestEventListAdd tests adding events to an eventList
@@ -21,14 +28,14 @@ func TestEventListAdd(t *testing.T) {
 	}
 
 	// test adding new entry to empty list
-	if !evtList.Add(evt1) {
-		t.Error("could not add new event:", evt1)
+	if err := evtList.Add(evt1); err != nil {
+		t.Error("could not add new event:", evt1, err)
 	}
 	test(evt1, evtList.Get(evt1.Name))
 
 	// test overwriting existing entry
-	if evtList.Add(evt2) {
-		t.Error("could overwrite existing event:", evt2)
+	if err := evtList.Add(evt2); err != ErrExists {
+		t.Error("could overwrite existing event:", evt2, err)
 	}
 	test(evt1, evtList.Get(evt2.Name))
 
@@ -74,6 +81,32 @@ func TestEventListRemove(t *testing.T) {
 	test([]*Event{}, evtList.List())
 }
 
+// TestEventListReplace tests replacing events in an eventList
+func TestEventListReplace(t *testing.T) {
+	evtList := newEventList()
+	evt1 := &Event{Name: "evt1"}
+	evt2 := &Event{Name: "evt1"}
+	evt3 := &Event{Name: "evt3"}
+
+	// replace non-existant event
+	if err := evtList.Replace(evt1, evt2); err != ErrNotFound {
+		t.Error("replaced non-existant event")
+	}
+
+	// replace event
+	evtList.Add(evt1)
+	if err := evtList.Replace(evt1, evt2); err != nil ||
+		evtList.Get("evt1") != evt2 {
+		t.Error("event not replaced")
+	}
+
+	// replace already replaced event and event with other name
+	if evtList.Replace(evt1, evt2) == nil ||
+		evtList.Replace(evt2, evt3) == nil {
+		t.Error("replaced invalid event")
+	}
+}
+
 // TestEventListGet tests getting events from an eventList
 func TestEventListGet(t *testing.T) {
 	// prepare event list, some test events, test function
@@ -132,6 +165,91 @@ func TestEventListList(t *testing.T) {
 	test([]*Event{evt1, evt2, evt3}, evtList.List())
 }
 
+// TestRun tests running events and their run history
+func TestRun(t *testing.T) {
+	command.Add(&command.Command{
+		Name:       "test-run",
+		Executable: "sh",
+		Arguments:  []string{"-c", "echo test; exit 1"},
+		Timeout:    10 * time.Second,
+	})
+	e := &Event{Name: "e", Command: "test-run"}
+
+	// test empty history
+	if e.LastRun() != nil {
+		t.Errorf("got %v, want nil", e.LastRun())
+	}
+
+	// test run record
+	scheduled := time.Now()
+	r := e.Run(scheduled)
+	if r.Number != 1 ||
+		r.Scheduled != scheduled ||
+		r.Start.Before(scheduled) ||
+		r.End.Before(r.Start) ||
+		r.ExitCode != 1 ||
+		r.Error == "" ||
+		r.Stdout != "test\n" {
+		t.Errorf("invalid run record: %#v", r)
+	}
+	if e.LastRun() != r || e.GetRun(r.ID) != r {
+		t.Errorf("got %v, want %v", e.LastRun(), r)
+	}
+
+	// test run history size
+	for i := 0; i < HistorySize; i++ {
+		e.Run(time.Now())
+	}
+	runs := e.Runs()
+	if len(runs) != HistorySize {
+		t.Errorf("got %d, want %d", len(runs), HistorySize)
+	}
+	if e.GetRun(r.ID) != nil {
+		t.Errorf("got %v, want nil", e.GetRun(r.ID))
+	}
+	if last := e.LastRun(); last.Number != uint64(HistorySize)+1 {
+		t.Errorf("got %d, want %d", last.Number, HistorySize+1)
+	}
+
+	// test command not found
+	e.Command = "does not exist"
+	r = e.Run(time.Now())
+	if r.ExitCode != -1 || r.Error == "" {
+		t.Errorf("invalid run record: %#v", r)
+	}
+
+	// test run context environment variables
+	command.Add(&command.Command{
+		Name:       "test-env",
+		Executable: "sh",
+		Arguments: []string{"-c", "echo $SCHEDULE_EVENT_NAME " +
+			"$SCHEDULE_RUN_ID $SCHEDULE_RUN_NUMBER " +
+			"$SCHEDULE_SCHEDULED_TIME"},
+		Timeout: 10 * time.Second,
+	})
+	e.Command = "test-env"
+	r = e.Run(scheduled)
+	want := fmt.Sprintf("e %d %d %s\n", r.ID, r.Number,
+		scheduled.Format(time.RFC3339))
+	if r.Stdout != want {
+		t.Errorf("got %q, want %q", r.Stdout, want)
+	}
+
+	// test event timeout overriding command timeout
+	command.Add(&command.Command{
+		Name:       "test-timeout",
+		Executable: "sleep",
+		Arguments:  []string{"10"},
+		Timeout:    10 * time.Second,
+	})
+	e.Command = "test-timeout"
+	e.Timeout = 100 * time.Millisecond
+	r = e.Run(time.Now())
+	if r.Timeout != e.Timeout || !r.TimedOut {
+		t.Errorf("invalid run record: %#v", r)
+	}
+}
+
 // TestNextWait tests getting the next wait time
 func TestNextWait(t *testing.T) {
 	test := func(want, got time.Duration) {
@@ -179,31 +297,38 @@ func TestNextWait(t *testing.T) {
 	}
 }
 
-// TestScheduleWait tests scheduling the event after a wait time
-func TestScheduleWait(t *testing.T) {
-	// event without expiration date
-	e1 := &Event{}
-	e1.scheduleWait(-1)
-	e1.scheduleWait(0)
-	e1.scheduleWait(1 * time.Second)
+// TestScheduleStopDate tests scheduling events with stop dates
+func TestScheduleStopDate(t *testing.T) {
+	command.Add(&command.Command{
+		Name:       "test-stop-date",
+		Executable: "true",
+		Timeout:    10 * time.Second,
+	})
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	useFakeClock(t, now)
+	test := func(e *Event, want int) {
+		e.Command = "test-stop-date"
+		e.Schedule()
+		if got := len(e.Runs()); got != want {
+			t.Errorf("%s: got %d runs, want %d", e.Name, got, want)
+		}
+	}
+
+	// event without stop date
+	test(&Event{Name: "no-stop-date"}, 1)
 
 	// expired event
-	e2 := &Event{}
-	e2.StopDate = e2.StopDate.Add(1) // set valid date in the past
-	e2.scheduleWait(-1)
-	e2.scheduleWait(0)
-	e2.scheduleWait(1 * time.Second)
-
-	// event that will expire in 5 seconds from now
-	e3 := &Event{
-		StartDate: time.Now(),
-		StopDate:  time.Now().Add(5 * time.Second),
-	}
-	e3.scheduleWait(-1)
-	e3.scheduleWait(0)
-	e3.scheduleWait(1 * time.Second)
-	e3.scheduleWait(4 * time.Second) // expired
-	e3.scheduleWait(5 * time.Second) // expired
+	test(&Event{Name: "expired", StopDate: now.Add(-time.Second)}, 0)
+
+	// event that expires before its start date
+	test(&Event{
+		Name:      "expires-before-start",
+		StartDate: now.Add(time.Hour),
+		StopDate:  now.Add(time.Minute),
+	}, 0)
+
+	// event that expires at its start date
+	test(&Event{Name: "expires-at-start", StopDate: now}, 1)
 }
 
 // TestSchedule tests scheduling events
@@ -227,6 +352,378 @@ func TestSchedule(t *testing.T) {
 		WaitMin:  100 * time.Millisecond,
 	}
 	e4.Schedule()
+
+	// cron event
+	e5 := &Event{
+		Name:     "e5",
+		Command:  "test-schedule",
+		StopDate: time.Now().Add(2500 * time.Millisecond),
+		Cron:     "* * * * * *",
+	}
+	command.Add(&command.Command{
+		Name:       "test-schedule",
+		Executable: "true",
+		Timeout:    10 * time.Second,
+	})
+	e5.Schedule()
+	if n := len(e5.Runs()); n < 2 || n > 3 {
+		t.Errorf("got %d runs, want 2-3", n)
+	}
+	for _, r := range e5.Runs() {
+		if r.Scheduled.Sub(r.Scheduled.Truncate(time.Second)) >
+			10*time.Millisecond {
+			t.Errorf("run not scheduled at full second: %v",
+				r.Scheduled)
+		}
+	}
+
+	// cron event with start date in the future
+	e6 := &Event{
+		Name:      "e6",
+		Command:   "test-schedule",
+		StartDate: time.Now().Add(1500 * time.Millisecond),
+		StopDate:  time.Now().Add(2500 * time.Millisecond),
+		Cron:      "* * * * * *",
+	}
+	e6.Schedule()
+	if n := len(e6.Runs()); n != 1 {
+		t.Errorf("got %d runs, want 1", n)
+	}
+
+	// invalid cron event
+	e7 := &Event{Name: "e7", Cron: "invalid"}
+	e7.Schedule()
+}
+
+// useFakeClock replaces the clock and the random source of the event
+// package with fakes until the end of the test
+func useFakeClock(t *testing.T, now time.Time, numbers ...int64) *clock.Fake {
+	c := clock.NewFake(now)
+	oldClock, oldRand := getClock(), getRand()
+	SetClock(c)
+	SetRand(&clock.FakeRand{Numbers: numbers})
+	t.Cleanup(func() {
+		SetClock(oldClock)
+		SetRand(oldRand)
+	})
+	return c
+}
+
+// TestScheduleFakeClock tests scheduling periodic events deterministically
+// with a fake clock
+func TestScheduleFakeClock(t *testing.T) {
+	command.Add(&command.Command{
+		Name:       "test-fake-clock",
+		Executable: "true",
+		Timeout:    10 * time.Second,
+	})
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	test := func(e *Event, c *clock.Fake, steps []time.Duration,
+		want ...time.Time) {
+		// schedule event and advance clock in steps
+		done := make(chan struct{})
+		go func() {
+			e.Schedule()
+			close(done)
+		}()
+		for _, d := range steps {
+			c.BlockUntil(1)
+			c.Advance(d)
+		}
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: event not done", e.Name)
+		}
+
+		// check scheduled times of runs
+		got := []time.Time{}
+		for _, r := range e.Runs() {
+			got = append(got, r.Scheduled)
+		}
+		sort.Slice(got, func(i, j int) bool {
+			return got[i].Before(got[j])
+		})
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", e.Name, got, want)
+		}
+	}
+
+	// periodic event with fixed wait time
+	c := useFakeClock(t, start)
+	e := &Event{
+		Name:     "fixed",
+		Command:  "test-fake-clock",
+		StopDate: start.Add(210 * time.Second),
+		Periodic: true,
+		WaitMin:  time.Minute,
+	}
+	test(e, c, []time.Duration{time.Minute, time.Minute, time.Minute},
+		start, start.Add(time.Minute), start.Add(2*time.Minute),
+		start.Add(3*time.Minute))
+
+	// periodic event with random wait times
+	c = useFakeClock(t, start, 30000, 0, 59999)
+	e = &Event{
+		Name:     "random",
+		Command:  "test-fake-clock",
+		StopDate: start.Add(4 * time.Minute),
+		Periodic: true,
+		WaitMin:  time.Minute,
+		WaitMax:  2 * time.Minute,
+	}
+	test(e, c, []time.Duration{90 * time.Second, time.Minute},
+		start, start.Add(90*time.Second), start.Add(150*time.Second))
+
+	// fixed rate event with start date
+	c = useFakeClock(t, start)
+	e = &Event{
+		Name:      "fixed-rate",
+		Command:   "test-fake-clock",
+		StartDate: start.Add(10 * time.Second),
+		StopDate:  start.Add(150 * time.Second),
+		Periodic:  true,
+		WaitMin:   time.Minute,
+		Mode:      ModeFixedRate,
+		Overlap:   OverlapAllow,
+	}
+	test(e, c, []time.Duration{10 * time.Second, time.Minute, time.Minute},
+		start.Add(10*time.Second), start.Add(70*time.Second),
+		start.Add(130*time.Second))
+
+	// cron event
+	c = useFakeClock(t, start.Add(30*time.Second))
+	e = &Event{
+		Name:     "cron",
+		Command:  "test-fake-clock",
+		StopDate: start.Add(3 * time.Minute),
+		Cron:     "* * * * *",
+	}
+	test(e, c, []time.Duration{30 * time.Second, time.Minute, time.Minute},
+		start.Add(time.Minute), start.Add(2*time.Minute),
+		start.Add(3*time.Minute))
+}
+
+// TestPause tests pausing and resuming scheduled events
+func TestPause(t *testing.T) {
+	command.Add(&command.Command{
+		Name:       "test-pause",
+		Executable: "true",
+		Timeout:    10 * time.Second,
+	})
+	e := &Event{
+		Name:     "pause",
+		Command:  "test-pause",
+		StopDate: time.Now().Add(time.Second),
+		Periodic: true,
+		WaitMin:  100 * time.Millisecond,
+		Paused:   true,
+	}
+
+	// resume paused event after half of its runs
+	go func() {
+		time.Sleep(450 * time.Millisecond)
+		e.Resume()
+	}()
+	e.Schedule()
+	if n := len(e.Runs()); n < 3 || n > 6 {
+		t.Errorf("got %d runs, want 3-6", n)
+	}
+
+	// pause event
+	e.Pause()
+	if !e.IsPaused() {
+		t.Errorf("got %t, want true", e.IsPaused())
+	}
+	e.Resume()
+	if e.IsPaused() {
+		t.Errorf("got %t, want false", e.IsPaused())
+	}
+
+	// paused one-shot event waits for its resume
+	e = &Event{
+		Name:    "pause-once",
+		Command: "test-pause",
+		Paused:  true,
+	}
+	done := make(chan struct{})
+	go func() {
+		e.Schedule()
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	e.mutex.Lock()
+	next := e.next
+	e.mutex.Unlock()
+	if len(e.Runs()) != 0 || e.State() != StatePaused || next.IsZero() {
+		t.Errorf("got %d runs, state %s, next run %v, want paused",
+			len(e.Runs()), e.State(), next)
+	}
+	e.Resume()
+	<-done
+	if n := len(e.Runs()); n != 1 {
+		t.Errorf("got %d runs, want 1", n)
+	}
+
+	// stopped one-shot event waiting for its resume is done
+	e = &Event{
+		Name:    "pause-stop",
+		Command: "test-pause",
+		Paused:  true,
+	}
+	done = make(chan struct{})
+	go func() {
+		e.Schedule()
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	e.Stop()
+	<-done
+	if n := len(e.Runs()); n != 0 {
+		t.Errorf("got %d runs, want 0", n)
+	}
+}
+
+// TestState tests getting the lifecycle state of events
+func TestState(t *testing.T) {
+	command.Add(&command.Command{
+		Name:       "test-state",
+		Executable: "sleep",
+		Arguments:  []string{"0.2"},
+		Timeout:    10 * time.Second,
+	})
+	command.Add(&command.Command{
+		Name:       "test-state-fail",
+		Executable: "false",
+		Timeout:    10 * time.Second,
+	})
+	test := func(e *Event, want string) {
+		if got := e.State(); got != want {
+			t.Errorf("%s: got %s, want %s", e.Name, got, want)
+		}
+	}
+
+	// scheduled, paused, running and done event
+	e := &Event{
+		Name:      "state",
+		Command:   "test-state",
+		StartDate: time.Now().Add(100 * time.Millisecond),
+	}
+	done := make(chan struct{})
+	go func() {
+		e.Schedule()
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	test(e, StateScheduled)
+	b, err := e.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := struct {
+		State    string
+		NextRun  time.Time
+		RunCount uint64
+	}{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if fields.State != StateScheduled || fields.NextRun.IsZero() ||
+		fields.RunCount != 0 {
+		t.Errorf("invalid json fields: %+v", fields)
+	}
+	e.Pause()
+	test(e, StatePaused)
+	e.Resume()
+	time.Sleep(150 * time.Millisecond)
+	test(e, StateRunning)
+	<-done
+	test(e, StateDone)
+
+	// failed event
+	e = &Event{Name: "state-fail", Command: "test-state-fail"}
+	e.Schedule()
+	test(e, StateFailed)
+	b, err = e.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := struct {
+		LastRun      time.Time
+		RunCount     uint64
+		FailureCount uint64
+	}{}
+	if err := json.Unmarshal(b, &counts); err != nil {
+		t.Fatal(err)
+	}
+	if counts.LastRun.IsZero() || counts.RunCount != 1 ||
+		counts.FailureCount != 1 {
+		t.Errorf("invalid json fields: %+v", counts)
+	}
+}
+
+// TestScheduleMode tests scheduling periodic events in fixed delay and fixed
+// rate modes with overlap policies
+func TestScheduleMode(t *testing.T) {
+	command.Add(&command.Command{
+		Name:       "test-mode",
+		Executable: "sleep",
+		Arguments:  []string{"0.3"},
+		Timeout:    10 * time.Second,
+	})
+	test := func(e *Event, min, max int) {
+		e.Name = "mode"
+		e.Command = "test-mode"
+		e.StopDate = time.Now().Add(time.Second)
+		e.Periodic = true
+		e.WaitMin = 100 * time.Millisecond
+		e.Schedule()
+		if n := len(e.Runs()); n < min || n > max {
+			t.Errorf("%s/%s: got %d runs, want %d-%d", e.Mode,
+				e.Overlap, n, min, max)
+		}
+	}
+
+	// fixed delay, runs every 400ms
+	test(&Event{}, 2, 3)
+	test(&Event{Mode: ModeFixedDelay}, 2, 3)
+
+	// fixed rate, skip overlapping runs, runs every 400ms
+	test(&Event{Mode: ModeFixedRate}, 2, 3)
+	test(&Event{Mode: ModeFixedRate, Overlap: OverlapSkip}, 2, 3)
+
+	// fixed rate, queue overlapping runs, all queued runs are executed
+	// after the stop date
+	test(&Event{Mode: ModeFixedRate, Overlap: OverlapQueue}, 9, 11)
+
+	// fixed rate, allow overlapping runs, runs every 100ms
+	test(&Event{Mode: ModeFixedRate, Overlap: OverlapAllow}, 9, 11)
+
+	// fixed rate, allow 2 overlapping runs, runs twice every 400ms
+	test(&Event{
+		Mode:          ModeFixedRate,
+		Overlap:       OverlapAllow,
+		MaxConcurrent: 2,
+	}, 4, 6)
+}
+
+// TestFireQueue tests limiting the queued runs of fixed rate events
+func TestFireQueue(t *testing.T) {
+	test := func(e *Event, want int) {
+		// previous run still running, queue runs
+		e.Name = "fire-queue"
+		e.Mode = ModeFixedRate
+		e.Overlap = OverlapQueue
+		e.active = 1
+		for i := 0; i < 2*want; i++ {
+			e.fire(nil, time.Now())
+		}
+		if n := len(e.queue); n != want {
+			t.Errorf("got %d queued runs, want %d", n, want)
+		}
+	}
+	test(&Event{}, DefaultMaxQueued)
+	test(&Event{MaxConcurrent: 3}, 3)
 }
 
 // TestStop tests stopping scheduled events
@@ -246,18 +743,64 @@ func TestStop(t *testing.T) {
 	e2.WaitMin = 100 * time.Millisecond
 	go e2.Schedule()
 	e2.Stop()
+
+	// event with running command
+	command.Add(&command.Command{
+		Name:       "test-stop",
+		Executable: "sleep",
+		Arguments:  []string{"10"},
+	})
+	e3 := NewEvent()
+	e3.Name = "e3"
+	e3.Command = "test-stop"
+	e3.Periodic = true
+	e3.WaitMin = 100 * time.Millisecond
+	done := make(chan struct{})
+	go func() {
+		e3.Schedule()
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	start := time.Now()
+	e3.Stop()
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("stop took %v", d)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not stopped")
+	}
+	if r := e3.LastRun(); r == nil || !r.Canceled || !r.Terminated {
+		t.Errorf("invalid run record: %#v", r)
+	}
+
+	// stopped event does not start its command
+	command.Add(&command.Command{
+		Name:       "test-stop-echo",
+		Executable: "echo",
+		Arguments:  []string{"started"},
+	})
+	e4 := NewEvent()
+	e4.Name = "e4"
+	e4.Command = "test-stop-echo"
+	e4.Stop()
+	if r := e4.Run(time.Now()); !r.Canceled || r.Stdout != "" {
+		t.Errorf("invalid run record: %#v", r)
+	}
 }
 
 // TestJSON tests conversion from and to json
 func TestJSON(t *testing.T) {
 	e1 := &Event{
-		Command:   "test",
-		StartDate: time.Now().Local(),
-		StopDate:  time.Now().Add(10 * time.Second).Local(),
-		Timeout:   30 * time.Second,
-		Periodic:  true,
-		WaitMin:   0,
-		WaitMax:   time.Second,
+		Command:    "test",
+		Parameters: map[string]string{"param": "value"},
+		StartDate:  time.Now().Local(),
+		StopDate:   time.Now().Add(10 * time.Second).Local(),
+		Timeout:    30 * time.Second,
+		Periodic:   true,
+		WaitMin:    0,
+		WaitMax:    time.Second,
 	}
 
 	// convert to json
@@ -271,12 +814,185 @@ func TestJSON(t *testing.T) {
 	if err != nil {
 		t.Errorf("got %v, want nil", err)
 	}
-	if e2.stop == nil {
-		t.Error("got e2.stop == nil, want e2.stop != nil")
+	if e2.ctx == nil {
+		t.Error("got e2.ctx == nil, want e2.ctx != nil")
 	}
-	e2.stop = nil // workaround for comparison
-	if *e1 != *e2 {
+	e2.ctx, e2.cancel = nil, nil // workaround for comparison
+	e2.runCtx, e2.runCancel = nil, nil
+	if !reflect.DeepEqual(e1, e2) {
 		t.Errorf("got e1 != e2, want e1 == e2\ne1: %#v\ne2: %#v",
 			e1, e2)
 	}
 }
+
+// TestFinish tests keeping finished events in the event list
+func TestFinish(t *testing.T) {
+	defer Flush()
+	defer func(size int) {
+		FinishedSize = size
+	}(FinishedSize)
+	FinishedSize = 1
+	command.Add(&command.Command{
+		Name:       "test-finish",
+		Executable: "false",
+		Timeout:    10 * time.Second,
+	})
+	e1 := NewEvent()
+	e1.Name = "finish1"
+	e1.Command = "test-finish"
+	e1.Retry = &Retry{MaxAttempts: 2}
+	e2 := NewEvent()
+	e2.Name = "finish2"
+	Add(e1)
+	Add(e2)
+
+	// finished event is kept with its state and retry count
+	e1.Schedule()
+	if Get(e1.Name) != e1 || e1.State() != StateFailed {
+		t.Errorf("got %v, %s, want finished event", Get(e1.Name),
+			e1.State())
+	}
+	b, err := e1.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := struct{ RetryCount uint64 }{}
+	if err := json.Unmarshal(b, &status); err != nil ||
+		status.RetryCount != 1 {
+		t.Errorf("got retry count %d, %v, want 1", status.RetryCount,
+			err)
+	}
+
+	// oldest finished event is removed
+	e2.Schedule()
+	if Get(e1.Name) != nil || Get(e2.Name) != e2 {
+		t.Error("oldest finished event not removed")
+	}
+
+	// finished event is replaced by new event with the same name
+	e3 := NewEvent()
+	e3.Name = "finish2"
+	if err := Add(e3); err != nil || Get(e3.Name) != e3 {
+		t.Errorf("finished event not replaced: %v", err)
+	}
+	if err := Add(e3); err != ErrExists {
+		t.Errorf("got %v, want %v", err, ErrExists)
+	}
+}
+
+// TestReplace tests replacing events in the event list
+func TestReplace(t *testing.T) {
+	defer Flush()
+	command.Add(&command.Command{
+		Name:       "test-replace",
+		Executable: "true",
+	})
+	e1 := NewEvent()
+	e1.Name = "replace"
+	e1.Command = "test-replace"
+	e1.WaitMin = time.Second
+	e1.Run(time.Now())
+	Add(e1)
+
+	// patch event
+	e2, err := e1.Patch([]byte(`{"WaitMin":2000000000,"Periodic":true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e2.Name != e1.Name || e2.Command != e1.Command ||
+		e2.WaitMin != 2*time.Second || !e2.Periodic {
+		t.Errorf("invalid patched event: %#v", e2)
+	}
+	if _, err := e1.Patch([]byte(`{"WaitMin":"invalid"}`)); err == nil {
+		t.Error("got nil, want !nil")
+	}
+
+	// replace event, run history is kept
+	if err := Replace(e1, e2); err != nil || Get("replace") != e2 {
+		t.Fatal("event not replaced")
+	}
+	if !reflect.DeepEqual(e1.Runs(), e2.Runs()) {
+		t.Errorf("got runs %v, want %v", e2.Runs(), e1.Runs())
+	}
+	if run := e2.Run(time.Now()); run.Number != 2 {
+		t.Errorf("got run number %d, want 2", run.Number)
+	}
+	if err := Replace(e1, e2); err != ErrNotFound {
+		t.Error("replaced already replaced event")
+	}
+
+	// replace event with running command, run is not stopped and added
+	// to the run history of the replacing event
+	command.Add(&command.Command{
+		Name:       "test-replace-sleep",
+		Executable: "sleep",
+		Arguments:  []string{"0.2"},
+		Timeout:    10 * time.Second,
+	})
+	e3 := NewEvent()
+	e3.Name = "replace"
+	e3.Command = "test-replace-sleep"
+	e2.Command = "test-replace-sleep"
+	done := make(chan *Run)
+	go func() {
+		done <- e2.Run(time.Now())
+	}()
+	for e2.State() != StateRunning {
+		time.Sleep(time.Millisecond)
+	}
+	if err := Replace(e2, e3); err != nil {
+		t.Fatal(err)
+	}
+	e2.Retire()
+	run := <-done
+	if run.Error != "" || run.Canceled {
+		t.Errorf("got %+v, want successful run", run)
+	}
+	if last := e3.LastRun(); last != run {
+		t.Errorf("got last run %v, want %v", last, run)
+	}
+}
+
+// TestCheck tests checking events and their field errors
+func TestCheck(t *testing.T) {
+	command.Add(&command.Command{
+		Name:       "test-check",
+		Executable: "true",
+	})
+
+	// valid event
+	e := &Event{Name: "check", Command: "test-check"}
+	if err := e.Check(); err != nil {
+		t.Errorf("got %v, want nil", err)
+	}
+
+	// invalid events
+	for _, test := range []struct {
+		event *Event
+		field string
+		msg   string
+	}{
+		{&Event{Command: "unknown"}, "Command",
+			"command not found: unknown"},
+		{&Event{Command: "test-check", WaitMin: 2, WaitMax: 1},
+			"WaitMax", "WaitMax < WaitMin"},
+		{&Event{Command: "test-check", Periodic: true}, "WaitMin",
+			"WaitMin == 0 in periodic event"},
+		{&Event{Command: "test-check", Mode: "invalid"}, "Mode",
+			"invalid mode: invalid"},
+		{&Event{Command: "test-check", Cron: "invalid"}, "Cron", ""},
+		{&Event{Command: "test-check", OnFailure: &Hook{}},
+			"OnFailure", "hook without command or event"},
+	} {
+		var f *FieldError
+		if !errors.As(test.event.Check(), &f) {
+			t.Errorf("%s: got no field error", test.field)
+			continue
+		}
+		if f.Field != test.field ||
+			test.msg != "" && f.Message != test.msg {
+			t.Errorf("got %s: %s, want %s: %s", f.Field, f.Message,
+				test.field, test.msg)
+		}
+	}
+}
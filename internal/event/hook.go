@@ -0,0 +1,149 @@
+package event
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/hwipl/schedule-events/internal/api"
+	"github.com/hwipl/schedule-events/internal/command"
+)
+
+// Hook is a follow-up command and/or event that runs after a run of an event
+type Hook struct {
+	Command string
+	Event   string
+}
+
+// check checks if the hook is valid
+func (h *Hook) check() error {
+	if h.Command == "" && h.Event == "" {
+		return errors.New("hook without command or event")
+	}
+	if h.Command != "" {
+		c := command.Get(h.Command)
+		if c == nil {
+			return fmt.Errorf("hook command not found: %s",
+				h.Command)
+		}
+		if err := c.CheckParameters(nil); err != nil {
+			return fmt.Errorf("hook command %s: %w", h.Command, err)
+		}
+	}
+	return nil
+}
+
+// trigger is the run of an event that triggered a hook
+type trigger struct {
+	event string
+	run   *Run
+}
+
+// environ returns the environment variables with the context of the trigger
+func (t *trigger) environ() []string {
+	if t == nil {
+		return nil
+	}
+	return []string{
+		"SCHEDULE_TRIGGER_EVENT=" + t.event,
+		fmt.Sprintf("SCHEDULE_TRIGGER_RUN_ID=%d", t.run.ID),
+		fmt.Sprintf("SCHEDULE_TRIGGER_EXIT_CODE=%d", t.run.ExitCode),
+		fmt.Sprintf("SCHEDULE_TRIGGER_OUTPUT=%s/events/%s/output?run=%d",
+			api.Prefix, url.PathEscape(t.event), t.run.ID),
+	}
+}
+
+// CheckHooks checks if the hooks of the event are valid; invalid hooks are
+// reported with a FieldError
+func (e *Event) CheckHooks() error {
+	for _, h := range []struct {
+		field string
+		hook  *Hook
+	}{
+		{"OnSuccess", e.OnSuccess},
+		{"OnFailure", e.OnFailure},
+		{"OnTimeout", e.OnTimeout},
+	} {
+		if h.hook == nil {
+			continue
+		}
+		if err := h.hook.check(); err != nil {
+			return fieldError(h.field, "%v", err)
+		}
+	}
+	return nil
+}
+
+// hook returns the hook of the event for the outcome of run
+func (e *Event) hook(run *Run) (string, *Hook) {
+	switch {
+	case run.Error == "":
+		return "success", e.OnSuccess
+	case run.TimedOut && e.OnTimeout != nil:
+		return "timeout", e.OnTimeout
+	default:
+		return "failure", e.OnFailure
+	}
+}
+
+// runHooks runs the hook of the event for the outcome of run in workers of
+// scheduler s and calls done afterwards
+func (e *Event) runHooks(s *Scheduler, run *Run, done func()) {
+	name, h := e.hook(run)
+	if h == nil {
+		done()
+		return
+	}
+	t := &trigger{event: e.Name, run: run}
+
+	// run hook event after hook command
+	hookEvent := func() {
+		if h.Event == "" {
+			done()
+			return
+		}
+		log.Printf("Event %s: running %s hook event %s for run %d",
+			e.Name, name, h.Event, run.ID)
+		evt := Get(h.Event)
+		if evt == nil {
+			log.Printf("Event %s: hook event not found: %s",
+				e.Name, h.Event)
+			done()
+			return
+		}
+		scheduled := getClock().Now()
+		s.submit(evt.Priority, func(delay time.Duration) {
+			evt.execute(s, scheduled, t, delay, func(r *Run) {
+				log.Printf("Event %s: %s hook event %s for "+
+					"run %d done: run %d", e.Name, name,
+					h.Event, run.ID, r.ID)
+				done()
+			})
+		})
+	}
+
+	// run hook command
+	if h.Command == "" {
+		hookEvent()
+		return
+	}
+	log.Printf("Event %s: running %s hook command %s for run %d",
+		e.Name, name, h.Command, run.ID)
+	s.submit(e.Priority, func(time.Duration) {
+		c := command.Get(h.Command)
+		if c == nil {
+			log.Printf("Event %s: hook command not found: %s",
+				e.Name, h.Command)
+		} else {
+			result, err := c.Run(e.runContext(), &command.Options{
+				Env: append(run.environ(e), t.environ()...),
+			})
+			log.Printf("Event %s: %s hook command %s for run %d "+
+				"done: exit code %d, error %v", e.Name, name,
+				h.Command, run.ID, result.ExitCode, err)
+		}
+		hookEvent()
+	})
+}
@@ -0,0 +1,150 @@
+package event
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hwipl/schedule-events/internal/command"
+)
+
+// TestCheckHooks tests checking hooks of events
+func TestCheckHooks(t *testing.T) {
+	command.Add(&command.Command{
+		Name:       "test-hook-check",
+		Executable: "true",
+	})
+	command.Add(&command.Command{
+		Name:       "test-hook-check-param",
+		Executable: "echo",
+		Arguments:  []string{"{{param}}"},
+		Parameters: map[string]*command.Parameter{"param": {}},
+	})
+
+	// valid hooks
+	for _, e := range []*Event{
+		{},
+		{OnSuccess: &Hook{Command: "test-hook-check"}},
+		{OnFailure: &Hook{Event: "unknown"}},
+		{OnTimeout: &Hook{Command: "test-hook-check", Event: "e"}},
+	} {
+		if err := e.CheckHooks(); err != nil {
+			t.Errorf("got %v, want nil", err)
+		}
+	}
+
+	// invalid hooks
+	for _, e := range []*Event{
+		{OnSuccess: &Hook{}},
+		{OnFailure: &Hook{Command: "does not exist"}},
+		{OnTimeout: &Hook{Command: "test-hook-check-param"}},
+	} {
+		if err := e.CheckHooks(); err == nil {
+			t.Error("got nil, want !nil")
+		}
+	}
+}
+
+// TestRunHooks tests running hooks of events
+func TestRunHooks(t *testing.T) {
+	defer Flush()
+	command.Add(&command.Command{
+		Name:       "test-hook-ok",
+		Executable: "true",
+		Timeout:    10 * time.Second,
+	})
+	command.Add(&command.Command{
+		Name:       "test-hook-fail",
+		Executable: "false",
+		Timeout:    10 * time.Second,
+	})
+	command.Add(&command.Command{
+		Name:       "test-hook-sleep",
+		Executable: "sleep",
+		Arguments:  []string{"10"},
+		Timeout:    100 * time.Millisecond,
+	})
+	command.Add(&command.Command{
+		Name:       "test-hook-env",
+		Executable: "sh",
+		Arguments:  []string{"-c", "env | grep SCHEDULE_TRIGGER_"},
+		Timeout:    10 * time.Second,
+	})
+	onSuccess := &Event{Name: "on-success", Command: "test-hook-env"}
+	onFailure := &Event{Name: "on-failure", Command: "test-hook-env"}
+	onTimeout := &Event{Name: "on-timeout", Command: "test-hook-env"}
+	Add(onSuccess)
+	Add(onFailure)
+	Add(onTimeout)
+	hooks := func(e *Event) *Event {
+		e.OnSuccess = &Hook{Event: "on-success"}
+		e.OnFailure = &Hook{Event: "on-failure"}
+		e.OnTimeout = &Hook{Event: "on-timeout"}
+		return e
+	}
+	test := func(hook *Event, run *Run) {
+		last := hook.LastRun()
+		if last == nil {
+			t.Fatalf("%s: hook event not run", hook.Name)
+		}
+		if last.TriggerRun != run.ID || last.TriggerEvent != "trigger" {
+			t.Errorf("%s: got trigger %s %d, want trigger %d",
+				hook.Name, last.TriggerEvent, last.TriggerRun,
+				run.ID)
+		}
+		for _, want := range []string{
+			"SCHEDULE_TRIGGER_EVENT=trigger\n",
+			"SCHEDULE_TRIGGER_OUTPUT=/api/v1/events/trigger/output?run=",
+		} {
+			if !strings.Contains(last.Stdout, want) {
+				t.Errorf("%s: %q not in output %q", hook.Name,
+					want, last.Stdout)
+			}
+		}
+	}
+
+	// success, failure and timeout hooks
+	run := hooks(&Event{Name: "trigger", Command: "test-hook-ok"}).Run(
+		time.Now())
+	test(onSuccess, run)
+	run = hooks(&Event{Name: "trigger", Command: "test-hook-fail"}).Run(
+		time.Now())
+	test(onFailure, run)
+	run = hooks(&Event{Name: "trigger", Command: "test-hook-sleep"}).Run(
+		time.Now())
+	test(onTimeout, run)
+
+	// failure hook for timeouts without timeout hook
+	e := hooks(&Event{Name: "trigger", Command: "test-hook-sleep"})
+	e.OnTimeout = nil
+	run = e.Run(time.Now())
+	test(onFailure, run)
+
+	// hook events do not run their own hooks
+	onFailure.OnSuccess = &Hook{Event: "on-timeout"}
+	n := len(onTimeout.Runs())
+	hooks(&Event{Name: "trigger", Command: "test-hook-fail"}).Run(
+		time.Now())
+	if len(onTimeout.Runs()) != n {
+		t.Error("hook event ran its own hooks")
+	}
+}
+
+// TestTriggerEnviron tests the environment variables of triggers
+func TestTriggerEnviron(t *testing.T) {
+	tr := &trigger{
+		event: "trigger event/1",
+		run:   &Run{ID: 7, Result: command.Result{ExitCode: 1}},
+	}
+	want := []string{
+		"SCHEDULE_TRIGGER_EVENT=trigger event/1",
+		"SCHEDULE_TRIGGER_RUN_ID=7",
+		"SCHEDULE_TRIGGER_EXIT_CODE=1",
+		"SCHEDULE_TRIGGER_OUTPUT=/api/v1/events/trigger%20event%2F1/" +
+			"output?run=7",
+	}
+	if got := tr.environ(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,52 @@
+package event
+
+import (
+	"time"
+
+	"github.com/hwipl/schedule-events/internal/cron"
+)
+
+// Preview returns the next n run times of the event after time from without
+// scheduling it; random wait times are sampled, run durations and
+// dependencies are ignored
+func (e *Event) Preview(from time.Time, n int) ([]time.Time, error) {
+	times := []time.Time{}
+	add := func(t time.Time) bool {
+		if len(times) >= n ||
+			!e.StopDate.IsZero() && t.After(e.StopDate) {
+			return false
+		}
+		times = append(times, t)
+		return len(times) < n
+	}
+
+	// get run times matching the cron expression, not before the start
+	// date
+	if e.Cron != "" {
+		s, err := cron.Parse(e.Cron)
+		if err != nil {
+			return nil, err
+		}
+		t := from
+		if e.StartDate.After(t) {
+			t = e.StartDate.Add(-time.Nanosecond)
+		}
+		for {
+			t = s.Next(t)
+			if t.IsZero() || !add(t) {
+				break
+			}
+		}
+		return times, nil
+	}
+
+	// get first run time and periodic run times
+	t := from
+	if e.StartDate.After(t) {
+		t = e.StartDate
+	}
+	for add(t) && e.Periodic {
+		t = t.Add(e.nextWait())
+	}
+	return times, nil
+}
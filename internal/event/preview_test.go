@@ -0,0 +1,72 @@
+package event
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestPreview tests previewing run times of events
+func TestPreview(t *testing.T) {
+	from := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	test := func(e *Event, n int, want ...time.Time) {
+		got, err := e.Preview(from, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(want) == 0 {
+			want = []time.Time{}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", e.Name, got, want)
+		}
+	}
+
+	// single run now and at start date
+	test(&Event{Name: "now"}, 5, from)
+	test(&Event{Name: "start", StartDate: from.Add(time.Hour)}, 5,
+		from.Add(time.Hour))
+	test(&Event{Name: "none"}, 0)
+
+	// periodic runs with fixed wait time and stop date
+	e := &Event{
+		Name:      "periodic",
+		StartDate: from.Add(-time.Hour),
+		Periodic:  true,
+		WaitMin:   time.Minute,
+	}
+	test(e, 3, from, from.Add(time.Minute), from.Add(2*time.Minute))
+	e.StopDate = from.Add(90 * time.Second)
+	test(e, 3, from, from.Add(time.Minute))
+
+	// cron runs
+	e = &Event{
+		Name:      "cron",
+		StartDate: from.Add(30 * time.Minute),
+		Cron:      "0 * * * *",
+	}
+	test(e, 2, from.Add(time.Hour), from.Add(2*time.Hour))
+
+	// invalid cron expression
+	if _, err := (&Event{Cron: "invalid"}).Preview(from, 1); err == nil {
+		t.Error("got nil, want !nil")
+	}
+
+	// periodic runs with random wait times
+	e = &Event{
+		Name:     "random",
+		Periodic: true,
+		WaitMin:  time.Minute,
+		WaitMax:  2 * time.Minute,
+	}
+	times, err := e.Preview(from, 10)
+	if err != nil || len(times) != 10 {
+		t.Fatalf("got %d times, %v, want 10 times, nil", len(times), err)
+	}
+	for i := 1; i < len(times); i++ {
+		wait := times[i].Sub(times[i-1])
+		if wait < time.Minute || wait > 2*time.Minute {
+			t.Errorf("invalid wait time: %v", wait)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package event
+
+import (
+	"slices"
+	"time"
+)
+
+// Retry is the retry policy of an event for failed runs
+type Retry struct {
+	// MaxAttempts is the maximum number of attempts of a run including
+	// the first attempt
+	MaxAttempts int
+
+	// Delay is the delay before the first retry
+	Delay time.Duration
+
+	// Multiplier is multiplied with the delay after each retry; values
+	// below 1 are treated as 1
+	Multiplier float64
+
+	// MaxDelay is the maximum delay between retries, zero means no
+	// maximum
+	MaxDelay time.Duration
+
+	// ExitCodes are the exit codes of failed runs that are retried; empty
+	// means all failed runs are retried, runs that timed out or were
+	// killed have exit code -1
+	ExitCodes []int
+}
+
+// delay returns the delay before the retry number n, starting with 1
+func (r *Retry) delay(n int) time.Duration {
+	mult := max(r.Multiplier, 1)
+	delay := float64(r.Delay)
+	for i := 1; i < n; i++ {
+		delay *= mult
+		if r.MaxDelay > 0 && delay >= float64(r.MaxDelay) {
+			return r.MaxDelay
+		}
+	}
+	return time.Duration(delay)
+}
+
+// retryable returns whether run failed and can be retried in attempt
+// number n
+func (r *Retry) retryable(run *Run, n int) bool {
+	if r == nil || n > r.MaxAttempts {
+		return false
+	}
+	if run.Error == "" || run.Canceled {
+		return false
+	}
+	return len(r.ExitCodes) == 0 || slices.Contains(r.ExitCodes,
+		run.ExitCode)
+}
+
+// Valid returns whether the retry policy is valid
+func (r *Retry) Valid() bool {
+	return r.MaxAttempts >= 0 &&
+		r.Delay >= 0 &&
+		r.Multiplier >= 0 &&
+		r.MaxDelay >= 0
+}
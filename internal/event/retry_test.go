@@ -0,0 +1,125 @@
+package event
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hwipl/schedule-events/internal/command"
+)
+
+// TestRetryDelay tests getting the delay between retries
+func TestRetryDelay(t *testing.T) {
+	test := func(r *Retry, want ...time.Duration) {
+		for i, w := range want {
+			if got := r.delay(i + 1); got != w {
+				t.Errorf("retry %d: got %v, want %v", i+1, got, w)
+			}
+		}
+	}
+
+	// fixed delay
+	test(&Retry{Delay: time.Second}, time.Second, time.Second)
+	test(&Retry{Delay: time.Second, Multiplier: 0.5}, time.Second,
+		time.Second)
+
+	// exponential backoff
+	test(&Retry{Delay: time.Second, Multiplier: 2}, time.Second,
+		2*time.Second, 4*time.Second, 8*time.Second)
+
+	// exponential backoff with maximum delay
+	test(&Retry{
+		Delay:      time.Second,
+		Multiplier: 2,
+		MaxDelay:   3 * time.Second,
+	}, time.Second, 2*time.Second, 3*time.Second, 3*time.Second)
+}
+
+// TestRetryRetryable tests checking if runs can be retried
+func TestRetryRetryable(t *testing.T) {
+	failed := &Run{Error: "failed"}
+	failed.ExitCode = 2
+	canceled := &Run{Error: "canceled"}
+	canceled.Canceled = true
+	test := func(r *Retry, run *Run, n int, want bool) {
+		if got := r.retryable(run, n); got != want {
+			t.Errorf("got %t, want %t", got, want)
+		}
+	}
+
+	// no retry policy
+	test(nil, failed, 2, false)
+
+	// successful and canceled runs
+	test(&Retry{MaxAttempts: 3}, &Run{}, 2, false)
+	test(&Retry{MaxAttempts: 3}, canceled, 2, false)
+
+	// maximum attempts
+	test(&Retry{MaxAttempts: 3}, failed, 2, true)
+	test(&Retry{MaxAttempts: 3}, failed, 3, true)
+	test(&Retry{MaxAttempts: 3}, failed, 4, false)
+
+	// exit codes
+	test(&Retry{MaxAttempts: 3, ExitCodes: []int{1, 2}}, failed, 2, true)
+	test(&Retry{MaxAttempts: 3, ExitCodes: []int{1}}, failed, 2, false)
+}
+
+// TestRunRetry tests retrying failed runs of events
+func TestRunRetry(t *testing.T) {
+	command.Add(&command.Command{
+		Name:       "test-retry",
+		Executable: "sh",
+		Arguments:  []string{"-c", "exit 2"},
+		Timeout:    10 * time.Second,
+	})
+
+	// retry until maximum attempts
+	e := &Event{
+		Name:    "retry",
+		Command: "test-retry",
+		Retry: &Retry{
+			MaxAttempts: 3,
+			Delay:       10 * time.Millisecond,
+			Multiplier:  2,
+		},
+	}
+	run := e.Run(time.Now())
+	if run.Attempt != 3 || e.Retries() != 2 || len(e.Runs()) != 3 {
+		t.Errorf("got attempt %d, %d retries, %d runs, want 3, 2, 3",
+			run.Attempt, e.Retries(), len(e.Runs()))
+	}
+	for i, r := range e.Runs() {
+		if r.Attempt != i+1 || r.ExitCode != 2 {
+			t.Errorf("invalid run record: %#v", r)
+		}
+	}
+
+	// no retry for other exit codes
+	e = &Event{
+		Name:    "retry",
+		Command: "test-retry",
+		Retry: &Retry{
+			MaxAttempts: 3,
+			ExitCodes:   []int{1},
+		},
+	}
+	run = e.Run(time.Now())
+	if run.Attempt != 1 || e.Retries() != 0 || len(e.Runs()) != 1 {
+		t.Errorf("got attempt %d, %d retries, %d runs, want 1, 0, 1",
+			run.Attempt, e.Retries(), len(e.Runs()))
+	}
+
+	// stop event while waiting for retry
+	e = NewEvent()
+	e.Name = "retry"
+	e.Command = "test-retry"
+	e.Retry = &Retry{MaxAttempts: 3, Delay: time.Minute}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		e.Stop()
+	}()
+	run = e.Run(time.Now())
+	if run.Attempt != 1 || len(e.Runs()) != 1 {
+		t.Errorf("got attempt %d, %d runs, want 1, 1", run.Attempt,
+			len(e.Runs()))
+	}
+}
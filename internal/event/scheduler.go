@@ -0,0 +1,483 @@
+package event
+
+import (
+	"container/heap"
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/hwipl/schedule-events/internal/clock"
+	"github.com/hwipl/schedule-events/internal/cron"
+)
+
+var (
+	// MaxConcurrentRuns is the maximum number of concurrent runs of the
+	// default scheduler; it must be set before scheduling events
+	MaxConcurrentRuns = 256
+
+	// defaultScheduler is the scheduler used by Event.Schedule and
+	// Event.Start
+	defaultScheduler     *Scheduler
+	defaultSchedulerOnce sync.Once
+)
+
+// item is a scheduled event in the timer heap of a scheduler; items with a
+// call function are timers of single runs like retries of failed runs
+type item struct {
+	event *Event
+	when  time.Time
+	index int
+	cron  *cron.Schedule
+	call  func()
+	done  func()
+	stop  func() bool
+	once  sync.Once
+}
+
+// timerHeap is a min-heap of scheduled events ordered by their next run time
+type timerHeap []*item
+
+// Len returns the number of items in the heap
+func (h timerHeap) Len() int {
+	return len(h)
+}
+
+// Less returns whether item i runs before item j
+func (h timerHeap) Less(i, j int) bool {
+	return h[i].when.Before(h[j].when)
+}
+
+// Swap swaps the items i and j
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+// Push adds item x to the heap
+func (h *timerHeap) Push(x any) {
+	it := x.(*item)
+	it.index = len(*h)
+	*h = append(*h, it)
+}
+
+// Pop removes and returns the last item of the heap
+func (h *timerHeap) Pop() any {
+	old := *h
+	n := len(old)
+	it := old[n-1]
+	old[n-1] = nil
+	it.index = -1
+	*h = old[:n-1]
+	return it
+}
+
+// job is a run of an event in the run queue of a scheduler
+type job struct {
+	priority int
+	seq      uint64
+	queued   time.Time
+	run      func(delay time.Duration)
+}
+
+// jobHeap is a heap of jobs ordered by their priority, jobs with the same
+// priority are ordered by their position in the queue
+type jobHeap []*job
+
+// Len returns the number of jobs in the heap
+func (h jobHeap) Len() int {
+	return len(h)
+}
+
+// Less returns whether job i runs before job j
+func (h jobHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+// Swap swaps the jobs i and j
+func (h jobHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+// Push adds job x to the heap
+func (h *jobHeap) Push(x any) {
+	*h = append(*h, x.(*job))
+}
+
+// Pop removes and returns the last job of the heap
+func (h *jobHeap) Pop() any {
+	old := *h
+	n := len(old)
+	j := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return j
+}
+
+// Scheduler schedules events from a single loop with a min-heap of their next
+// run times and runs them in a bounded pool of workers; due runs wait in a
+// run queue ordered by the priority of their events while all workers are
+// busy
+type Scheduler struct {
+	mutex  sync.Mutex
+	timers timerHeap
+	wake   chan struct{}
+
+	jobsMutex sync.Mutex
+	jobsCond  *sync.Cond
+	jobs      jobHeap
+	jobsSeq   uint64
+	running   int
+}
+
+// wakeup wakes up the scheduler loop to check the timer heap
+func (s *Scheduler) wakeup() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// submit adds a run with priority to the run queue; run is called with the
+// queueing delay of the run when a worker is free
+func (s *Scheduler) submit(priority int, run func(delay time.Duration)) {
+	s.jobsMutex.Lock()
+	defer s.jobsMutex.Unlock()
+
+	s.jobsSeq++
+	heap.Push(&s.jobs, &job{
+		priority: priority,
+		seq:      s.jobsSeq,
+		queued:   getClock().Now(),
+		run:      run,
+	})
+	s.jobsCond.Signal()
+}
+
+// work runs jobs from the run queue
+func (s *Scheduler) work() {
+	for {
+		s.jobsMutex.Lock()
+		for len(s.jobs) == 0 {
+			s.jobsCond.Wait()
+		}
+		j := heap.Pop(&s.jobs).(*job)
+		s.running++
+		s.jobsMutex.Unlock()
+
+		j.run(getClock().Now().Sub(j.queued))
+
+		s.jobsMutex.Lock()
+		s.running--
+		s.jobsMutex.Unlock()
+	}
+}
+
+// Queue returns the number of queued runs waiting for a free worker and the
+// number of running runs
+func (s *Scheduler) Queue() (queued, running int) {
+	s.jobsMutex.Lock()
+	defer s.jobsMutex.Unlock()
+
+	return len(s.jobs), s.running
+}
+
+// loop fires the events in the timer heap at their next run times
+func (s *Scheduler) loop() {
+	for {
+		// get events that are due and the wait time until the next
+		// event
+		s.mutex.Lock()
+		now := getClock().Now()
+		due := []*item{}
+		for len(s.timers) > 0 && !s.timers[0].when.After(now) {
+			due = append(due, heap.Pop(&s.timers).(*item))
+		}
+		wait := time.Duration(-1)
+		if len(s.timers) > 0 {
+			wait = s.timers[0].when.Sub(now)
+		}
+		s.mutex.Unlock()
+
+		if len(due) > 0 {
+			for _, it := range due {
+				if it.call != nil {
+					it.stop()
+					it.call()
+					continue
+				}
+				s.fire(it)
+			}
+			continue
+		}
+
+		// wait for the next event or changes of the timer heap
+		var timer clock.Timer
+		var expired <-chan time.Time
+		if wait >= 0 {
+			timer = getClock().NewTimer(wait)
+			expired = timer.C()
+		}
+		select {
+		case <-expired:
+		case <-s.wake:
+			if timer != nil && !timer.Stop() {
+				<-expired
+			}
+		}
+	}
+}
+
+// push adds the event of it to the timer heap with the next run time when;
+// the event is done if it is stopped or when is after its stop date
+func (s *Scheduler) push(it *item, when time.Time) {
+	e := it.event
+	if now := getClock().Now(); when.Before(now) {
+		when = now
+	}
+
+	s.mutex.Lock()
+	if e.context().Err() != nil ||
+		!e.StopDate.IsZero() && when.After(e.StopDate) {
+		s.mutex.Unlock()
+		s.finish(it)
+		return
+	}
+	it.when = when
+	e.setNext(when)
+	heap.Push(&s.timers, it)
+	s.mutex.Unlock()
+
+	s.wakeup()
+}
+
+// after submits run with the priority of event e to the run queue after
+// duration d without blocking a worker; stopped is called instead if e is
+// stopped before
+func (s *Scheduler) after(e *Event, d time.Duration,
+	run func(delay time.Duration), stopped func()) {
+	it := &item{
+		event: e,
+		when:  getClock().Now().Add(d),
+		call: func() {
+			s.submit(e.Priority, run)
+		},
+	}
+
+	s.mutex.Lock()
+	heap.Push(&s.timers, it)
+	it.stop = context.AfterFunc(e.context(), func() {
+		s.mutex.Lock()
+		removed := it.index >= 0
+		if removed {
+			heap.Remove(&s.timers, it.index)
+		}
+		s.mutex.Unlock()
+
+		if removed {
+			stopped()
+			s.wakeup()
+		}
+	})
+	s.mutex.Unlock()
+
+	s.wakeup()
+}
+
+// remove removes the stopped event of it from the timer heap or stops
+// waiting for its resume
+func (s *Scheduler) remove(it *item) {
+	s.mutex.Lock()
+	removed := it.index >= 0
+	if removed {
+		heap.Remove(&s.timers, it.index)
+	}
+	s.mutex.Unlock()
+	if !removed {
+		removed = it.event.unpark() != nil
+	}
+
+	if removed {
+		it.event.setNext(time.Time{})
+		s.finish(it)
+		s.wakeup()
+	}
+}
+
+// finish finishes the event of it after its running runs; it only waits in
+// a new goroutine if fixed rate runs are still running
+func (s *Scheduler) finish(it *item) {
+	it.once.Do(func() {
+		it.stop()
+		e := it.event
+		done := func() {
+			e.running.Wait()
+			log.Println("Event done:", e.Name)
+			e.setFinished()
+			finish(e)
+			if it.done != nil {
+				it.done()
+			}
+		}
+
+		e.mutex.Lock()
+		active := e.active
+		e.mutex.Unlock()
+		if active > 0 {
+			go done()
+			return
+		}
+		done()
+	})
+}
+
+// first schedules the first run of the event of it
+func (s *Scheduler) first(it *item) {
+	e := it.event
+	now := getClock().Now()
+	if it.cron != nil {
+		s.nextCron(it, now)
+		return
+	}
+
+	// restored periodic events resume their periodic executions at their
+	// saved next run time if their start date passed
+	wait := e.StartDate.Sub(now)
+	if e.restored && e.Periodic && wait < 0 {
+		wait = e.nextWait()
+		if next := e.nextRun(); next.After(now) {
+			wait = next.Sub(now)
+		}
+	}
+	s.push(it, now.Add(wait))
+}
+
+// nextCron schedules the next run of the event of it at the next time after
+// from matching its cron expression, not before its start date
+func (s *Scheduler) nextCron(it *item, from time.Time) {
+	e := it.event
+	if e.StartDate.After(from) {
+		from = e.StartDate.Add(-time.Nanosecond)
+	}
+	next := it.cron.Next(from)
+	if next.IsZero() {
+		s.finish(it)
+		return
+	}
+	s.push(it, next)
+}
+
+// next schedules the next run of the event of it after its current run
+func (s *Scheduler) next(it *item) {
+	e := it.event
+	switch {
+	case it.cron != nil:
+		s.nextCron(it, getClock().Now())
+	case !e.Periodic:
+		s.finish(it)
+	case e.Mode == ModeFixedRate:
+		s.push(it, it.when.Add(e.nextWait()))
+	default:
+		s.push(it, getClock().Now().Add(e.nextWait()))
+	}
+}
+
+// fire runs the event of it; fixed delay events schedule their next run
+// after the current run, fixed rate events schedule their next run
+// immediately and run according to their overlap policy
+func (s *Scheduler) fire(it *item) {
+	e := it.event
+	e.setNext(time.Time{})
+	switch {
+	case e.context().Err() != nil:
+		s.finish(it)
+	case e.IsPaused() && (it.cron != nil || e.Periodic):
+		log.Printf("Event %s: paused, skipping run", e.Name)
+		s.next(it)
+	case e.park(it.when, func() { s.push(it, getClock().Now()) }):
+		// one-shot events wait for their resume instead of
+		// skipping their only run
+		log.Printf("Event %s: paused, waiting for resume", e.Name)
+	case e.Mode == ModeFixedRate:
+		e.fire(s, it.when)
+		s.next(it)
+	default:
+		scheduled := it.when
+		s.submit(e.Priority, func(delay time.Duration) {
+			// do not run events stopped while queued
+			if e.context().Err() != nil {
+				s.next(it)
+				return
+			}
+			e.execute(s, scheduled, nil, delay, func(*Run) {
+				s.next(it)
+			})
+		})
+	}
+}
+
+// Schedule schedules the event for execution without blocking; done is
+// called when the event is done
+func (s *Scheduler) Schedule(e *Event, done func()) {
+	log.Println("Scheduling event:", e.Name)
+
+	it := &item{event: e, index: -1, done: done}
+	it.stop = context.AfterFunc(e.context(), func() {
+		s.remove(it)
+	})
+	if e.Cron != "" {
+		c, err := cron.Parse(e.Cron)
+		if err != nil {
+			log.Printf("Event %s: %s", e.Name, err)
+			s.finish(it)
+			return
+		}
+		it.cron = c
+	}
+
+	// wait for dependencies in a new goroutine
+	if len(e.DependsOn) > 0 {
+		go func() {
+			if !e.waitDependencies() {
+				// stopped while waiting for dependencies
+				s.finish(it)
+				return
+			}
+			s.first(it)
+		}()
+		return
+	}
+	s.first(it)
+}
+
+// NewScheduler returns a new scheduler with a maximum number of concurrent
+// runs of events
+func NewScheduler(maxRuns int) *Scheduler {
+	s := &Scheduler{
+		wake: make(chan struct{}, 1),
+	}
+	s.jobsCond = sync.NewCond(&s.jobsMutex)
+	go s.loop()
+	for i := 0; i < max(maxRuns, 1); i++ {
+		go s.work()
+	}
+	return s
+}
+
+// Queue returns the number of queued runs waiting for a free worker and the
+// number of running runs of the default scheduler
+func Queue() (queued, running int) {
+	return getScheduler().Queue()
+}
+
+// getScheduler returns the default scheduler
+func getScheduler() *Scheduler {
+	defaultSchedulerOnce.Do(func() {
+		defaultScheduler = NewScheduler(MaxConcurrentRuns)
+	})
+	return defaultScheduler
+}
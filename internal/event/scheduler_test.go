@@ -0,0 +1,290 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hwipl/schedule-events/internal/command"
+)
+
+// TestScheduler tests scheduling events with a scheduler
+func TestScheduler(t *testing.T) {
+	command.Add(&command.Command{
+		Name:       "test-scheduler",
+		Executable: "true",
+		Timeout:    10 * time.Second,
+	})
+	s := NewScheduler(2)
+	wait := func(wg *sync.WaitGroup) {
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("events not done")
+		}
+	}
+
+	// events with more runs than workers
+	var wg sync.WaitGroup
+	events := []*Event{}
+	for i := 0; i < 10; i++ {
+		e := NewEvent()
+		e.Name = fmt.Sprintf("scheduler%d", i)
+		e.Command = "test-scheduler"
+		e.StartDate = time.Now().Add(time.Duration(i) *
+			10 * time.Millisecond)
+		e.StopDate = time.Now().Add(500 * time.Millisecond)
+		e.Periodic = true
+		e.WaitMin = 100 * time.Millisecond
+		events = append(events, e)
+		wg.Add(1)
+		s.Schedule(e, wg.Done)
+	}
+	wait(&wg)
+	for _, e := range events {
+		if n := len(e.Runs()); n < 3 || n > 5 {
+			t.Errorf("%s: got %d runs, want 3-5", e.Name, n)
+		}
+		if e.State() != StateDone {
+			t.Errorf("%s: got state %s, want %s", e.Name,
+				e.State(), StateDone)
+		}
+	}
+
+	// stop waiting event
+	e := NewEvent()
+	e.Name = "scheduler-stop"
+	e.StartDate = time.Now().Add(time.Hour)
+	wg.Add(1)
+	s.Schedule(e, wg.Done)
+	e.Stop()
+	wait(&wg)
+	if len(e.Runs()) != 0 {
+		t.Error("stopped event ran")
+	}
+}
+
+// TestSchedulerQueue tests the run queue of a scheduler
+func TestSchedulerQueue(t *testing.T) {
+	s := NewScheduler(1)
+
+	// block the only worker
+	block := make(chan struct{})
+	blocked := make(chan struct{})
+	s.submit(0, func(time.Duration) {
+		close(blocked)
+		<-block
+	})
+	<-blocked
+
+	// queue runs with priorities
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	order := []string{}
+	delays := []time.Duration{}
+	for _, r := range []struct {
+		name     string
+		priority int
+	}{
+		{"low", 0},
+		{"high1", 5},
+		{"medium", 1},
+		{"high2", 5},
+	} {
+		wg.Add(1)
+		s.submit(r.priority, func(delay time.Duration) {
+			defer wg.Done()
+			mutex.Lock()
+			defer mutex.Unlock()
+			order = append(order, r.name)
+			delays = append(delays, delay)
+		})
+	}
+	if queued, running := s.Queue(); queued != 4 || running != 1 {
+		t.Errorf("got %d queued, %d running, want 4, 1", queued,
+			running)
+	}
+
+	// queue run of event and stop event while it is queued
+	command.Add(&command.Command{
+		Name:       "test-scheduler-queue",
+		Executable: "true",
+		Timeout:    10 * time.Second,
+	})
+	e := NewEvent()
+	e.Name = "scheduler-queue"
+	e.Command = "test-scheduler-queue"
+	wg.Add(1)
+	s.Schedule(e, wg.Done)
+	for queued, _ := s.Queue(); queued != 5; queued, _ = s.Queue() {
+		time.Sleep(time.Millisecond)
+	}
+	e.Stop()
+
+	// run queued runs in order of their priorities
+	time.Sleep(10 * time.Millisecond)
+	close(block)
+	wg.Wait()
+	if n := len(e.Runs()); n != 0 {
+		t.Errorf("stopped event ran %d times", n)
+	}
+	want := []string{"high1", "high2", "medium", "low"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("got %v, want %v", order, want)
+	}
+	for _, d := range delays {
+		if d < 10*time.Millisecond {
+			t.Errorf("got queue delay %v, want >= 10ms", d)
+		}
+	}
+}
+
+// TestSchedulerRetry tests that events waiting for retries and hooks do not
+// block the workers of a scheduler
+func TestSchedulerRetry(t *testing.T) {
+	defer Flush()
+	command.Add(&command.Command{
+		Name:       "test-scheduler-retry",
+		Executable: "false",
+		Timeout:    10 * time.Second,
+	})
+	command.Add(&command.Command{
+		Name:       "test-scheduler-hook",
+		Executable: "true",
+		Timeout:    10 * time.Second,
+	})
+	s := NewScheduler(1)
+
+	// failed event waiting for its retry and a hook event
+	hook := NewEvent()
+	hook.Name = "scheduler-hook"
+	hook.Command = "test-scheduler-hook"
+	Add(hook)
+	retry := NewEvent()
+	retry.Name = "scheduler-retry"
+	retry.Command = "test-scheduler-retry"
+	retry.Retry = &Retry{MaxAttempts: 2, Delay: time.Hour}
+	retry.OnFailure = &Hook{Event: "scheduler-hook"}
+	retryDone := make(chan struct{})
+	s.Schedule(retry, func() {
+		close(retryDone)
+	})
+	for len(retry.Runs()) != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// other event runs while the failed event waits for its retry
+	e := NewEvent()
+	e.Name = "scheduler-other"
+	e.Command = "test-scheduler-hook"
+	done := make(chan struct{})
+	s.Schedule(e, func() {
+		close(done)
+	})
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("event blocked by retry")
+	}
+	if len(e.Runs()) != 1 {
+		t.Errorf("got %d runs, want 1", len(e.Runs()))
+	}
+
+	// stopped event does not retry but runs its hook
+	retry.Stop()
+	select {
+	case <-retryDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stopped event not done")
+	}
+	if n := len(retry.Runs()); n != 1 {
+		t.Errorf("got %d runs, want 1", n)
+	}
+	if n := len(hook.Runs()); n != 1 {
+		t.Errorf("got %d hook runs, want 1", n)
+	}
+}
+
+// TestSchedulerParkStopped tests that a paused one-shot event stopped while
+// it is not in the timer heap is not parked and is finished
+func TestSchedulerParkStopped(t *testing.T) {
+	s := NewScheduler(1)
+	e := NewEvent()
+	e.Name = "scheduler-park-stopped"
+	e.Paused = true
+	done := make(chan struct{})
+	it := &item{event: e, index: -1, done: func() {
+		close(done)
+	}}
+
+	// stop event while the item is not in the timer heap, so removing
+	// it does not finish it
+	removed := make(chan struct{})
+	it.stop = context.AfterFunc(e.context(), func() {
+		s.remove(it)
+		close(removed)
+	})
+	e.Stop()
+	<-removed
+
+	// stopped event is not parked but finished
+	if e.park(time.Now(), func() {}) {
+		t.Error("stopped event parked")
+	}
+	s.fire(it)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stopped event not done")
+	}
+	if n := len(e.Runs()); n != 0 {
+		t.Errorf("got %d runs, want 0", n)
+	}
+}
+
+// BenchmarkScheduler benchmarks scheduling and running many events; the
+// number of goroutines does not grow with the number of events
+func BenchmarkScheduler(b *testing.B) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	for _, n := range []int{1000, 10000, 100000} {
+		b.Run(fmt.Sprintf("events-%d", n), func(b *testing.B) {
+			goroutines := 0
+			for i := 0; i < b.N; i++ {
+				var wg sync.WaitGroup
+				start := time.Now()
+				for j := 0; j < n; j++ {
+					// command not found, runs do not
+					// start processes
+					e := NewEvent()
+					e.Name = fmt.Sprintf("benchmark%d", j)
+					e.Command = "benchmark"
+					e.StartDate = start.Add(
+						time.Duration(j%10) *
+							time.Millisecond)
+					wg.Add(1)
+					e.Start(wg.Done)
+				}
+				goroutines = max(goroutines,
+					runtime.NumGoroutine())
+				wg.Wait()
+			}
+			b.ReportMetric(float64(goroutines), "goroutines")
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/
+				float64(b.N*n), "ns/event")
+		})
+	}
+}
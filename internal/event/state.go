@@ -0,0 +1,164 @@
+package event
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	// StateDelay is the delay before changes of the event list are written
+	// to the state file; all changes within the delay are written at once
+	StateDelay = time.Second
+
+	// stateMutex protects the state file, stateDirty and stateTimer
+	stateMutex sync.Mutex
+
+	// stateFile is the file the event list is persisted in, empty if
+	// persistence is disabled
+	stateFile string
+
+	// stateDirty is whether the event list changed since it was written
+	// to the state file
+	stateDirty bool
+
+	// stateTimer writes pending changes to the state file after the
+	// delay, nil if no write is pending
+	stateTimer *time.Timer
+)
+
+// writeState atomically writes the events in evts to the state file in path
+func writeState(path string, evts []*Event) error {
+	b, err := json.MarshalIndent(evts, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	// write to temporary file in same directory and replace state file
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// stateEvents returns the events in the event list that are saved in the
+// state file; finished events are not saved
+func stateEvents() []*Event {
+	evts := []*Event{}
+	for _, e := range List() {
+		if !e.isFinished() {
+			evts = append(evts, e)
+		}
+	}
+	return evts
+}
+
+// saveState marks the event list as changed and writes all unfinished events
+// to the state file after the state delay if persistence is enabled
+func saveState() {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	if stateFile == "" {
+		return
+	}
+	stateDirty = true
+	if stateTimer == nil {
+		stateTimer = time.AfterFunc(StateDelay, flushState)
+	}
+}
+
+// flushState writes pending changes of the event list to the state file
+func flushState() {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	writePending()
+}
+
+// writePending writes pending changes of the event list to the state file;
+// must be called while holding stateMutex
+func writePending() {
+	if stateTimer != nil {
+		stateTimer.Stop()
+		stateTimer = nil
+	}
+	if !stateDirty || stateFile == "" {
+		return
+	}
+	stateDirty = false
+	if err := writeState(stateFile, stateEvents()); err != nil {
+		log.Println("Error saving state:", err)
+	}
+}
+
+// Persist enables persisting the event list in the state file in path and
+// writes the current event list to it; pending changes are written to the
+// previous state file first. An empty path disables persistence
+func Persist(path string) error {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	writePending()
+	stateFile = path
+	if stateFile == "" {
+		return nil
+	}
+	return writeState(stateFile, stateEvents())
+}
+
+// LoadState loads events from the state file in path and adds them to the
+// event list; a missing state file is not an error
+func LoadState(path string) error {
+	// read file
+	file, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// parse events and their next run times
+	evts := []*Event{}
+	if err := json.Unmarshal(file, &evts); err != nil {
+		return err
+	}
+	next := []struct{ NextRun time.Time }{}
+	if err := json.Unmarshal(file, &next); err != nil {
+		return err
+	}
+
+	// add restored events to event list, events from the events file
+	// take precedence
+	restored := []*Event{}
+	for i, e := range evts {
+		if Get(e.Name) != nil {
+			log.Printf("Event %s: event in events file replaces "+
+				"event in state file", e.Name)
+			continue
+		}
+		e.init()
+		e.restored = true
+		e.next = next[i].NextRun
+		restored = append(restored, e)
+	}
+	addEvents(restored)
+	return nil
+}
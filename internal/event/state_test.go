@@ -0,0 +1,162 @@
+package event
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hwipl/schedule-events/internal/command"
+)
+
+// TestPersist tests persisting the event list in a state file
+func TestPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	defer Flush()
+	defer Persist("")
+	defer func(delay time.Duration) {
+		StateDelay = delay
+	}(StateDelay)
+	StateDelay = time.Hour
+	read := func(want []string) {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		evts := []*Event{}
+		if err := json.Unmarshal(b, &evts); err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for _, e := range evts {
+			got = append(got, e.Name)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+	test := func(want []string) {
+		flushState()
+		read(want)
+	}
+
+	// test empty event list
+	if err := Persist(path); err != nil {
+		t.Fatal(err)
+	}
+	test([]string{})
+
+	// test adding events
+	e1 := &Event{Name: "persist1", Periodic: true, WaitMin: time.Second}
+	e2 := &Event{Name: "persist2"}
+	Add(e1)
+	Add(e2)
+	test([]string{"persist1", "persist2"})
+
+	// test removing events
+	Remove(e2)
+	test([]string{"persist1"})
+
+	// test delayed writes, pending changes are written when persistence
+	// is disabled
+	Add(e2)
+	read([]string{"persist1"})
+	if err := Persist(""); err != nil {
+		t.Fatal(err)
+	}
+	read([]string{"persist1", "persist2"})
+
+	// test disabled persistence
+	Flush()
+	test([]string{"persist1", "persist2"})
+}
+
+// TestLoadState tests restoring events from a state file
+func TestLoadState(t *testing.T) {
+	defer Flush()
+
+	// test missing state file
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := LoadState(path); err != nil {
+		t.Errorf("got %v, want nil", err)
+	}
+
+	// test invalid state file
+	if err := os.WriteFile(path, []byte("invalid"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadState(path); err == nil {
+		t.Errorf("got nil, want !nil")
+	}
+
+	// test restoring events with their next run times
+	next := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	evts := []*Event{{Name: "restore1", next: next}, {Name: "restore2"}}
+	if err := writeState(path, evts); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadState(path); err != nil {
+		t.Errorf("got %v, want nil", err)
+	}
+	for _, e := range evts {
+		got := Get(e.Name)
+		if got == nil || !got.restored || got.context() == nil {
+			t.Errorf("event %s not restored: %v", e.Name, got)
+		}
+	}
+	if got := Get("restore1").nextRun(); !got.Equal(next) {
+		t.Errorf("got next run %v, want %v", got, next)
+	}
+
+	// test events already in the event list are not replaced
+	Flush()
+	e := &Event{Name: "restore1"}
+	Add(e)
+	if err := LoadState(path); err != nil {
+		t.Errorf("got %v, want nil", err)
+	}
+	if Get("restore1") != e || Get("restore2") == nil {
+		t.Error("existing event replaced by restored event")
+	}
+}
+
+// TestScheduleRestored tests scheduling restored periodic events
+func TestScheduleRestored(t *testing.T) {
+	// restored periodic event resumes periodic schedule after its start
+	// date passed and does not run immediately
+	e := &Event{
+		Name:      "restored",
+		StartDate: time.Now().Add(-time.Hour),
+		StopDate:  time.Now().Add(500 * time.Millisecond),
+		Periodic:  true,
+		WaitMin:   time.Second,
+		restored:  true,
+	}
+	e.Schedule()
+	if n := len(e.Runs()); n != 0 {
+		t.Errorf("got %d runs, want 0", n)
+	}
+
+	// restored periodic event runs at its saved next run time
+	command.Add(&command.Command{
+		Name:       "test-restored",
+		Executable: "true",
+		Timeout:    10 * time.Second,
+	})
+	e = &Event{
+		Name:      "restored",
+		Command:   "test-restored",
+		StartDate: time.Now().Add(-time.Hour),
+		StopDate:  time.Now().Add(500 * time.Millisecond),
+		Periodic:  true,
+		WaitMin:   time.Hour,
+		restored:  true,
+		next:      time.Now().Add(100 * time.Millisecond),
+	}
+	e.Schedule()
+	if n := len(e.Runs()); n != 1 {
+		t.Errorf("got %d runs, want 1", n)
+	}
+}
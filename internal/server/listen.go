@@ -0,0 +1,98 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// unixPrefix is the prefix of unix domain socket addresses
+	unixPrefix = "unix:"
+)
+
+// removeStaleSocket removes the unix domain socket in path if no server
+// listens on it anymore
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode().Type() != fs.ModeSocket {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+
+	// check if socket is still in use
+	conn, err := net.Dial("unix", path)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("socket %s is in use", path)
+	}
+	log.Println("Removing stale socket:", path)
+	return os.Remove(path)
+}
+
+// unixListener is a listener on a unix domain socket that removes the socket
+// file when it is closed
+type unixListener struct {
+	net.Listener
+	path string
+}
+
+// Close closes the listener and removes the socket file
+func (l *unixListener) Close() error {
+	err := l.Listener.Close()
+	if err := os.Remove(l.path); err != nil &&
+		!errors.Is(err, fs.ErrNotExist) {
+		log.Println(err)
+	}
+	return err
+}
+
+// listenUnix listens on the unix domain socket in path and sets the
+// permissions of the socket file to mode; the socket is created in a private
+// temporary directory and only linked to path after setting mode, so it is
+// never accessible with other permissions
+func listenUnix(path string, mode fs.FileMode) (net.Listener, error) {
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".socket-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	tmp := filepath.Join(dir, "s")
+	l, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(tmp, mode); err != nil {
+		l.Close()
+		return nil, err
+	}
+	if err := os.Link(tmp, path); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return &unixListener{Listener: l, path: path}, nil
+}
+
+// listen listens on address, i.e., on a tcp address or on a unix domain
+// socket with the prefix "unix:" and the permissions mode
+func listen(address string, mode fs.FileMode) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(address, unixPrefix); ok {
+		return listenUnix(path, mode)
+	}
+	return net.Listen("tcp", address)
+}
@@ -0,0 +1,107 @@
+//go:build unix
+
+package server
+
+import (
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestRemoveStaleSocket tests removing stale unix domain sockets
+func TestRemoveStaleSocket(t *testing.T) {
+	dir := t.TempDir()
+
+	// test not existing socket
+	if err := removeStaleSocket(filepath.Join(dir, "none")); err != nil {
+		t.Errorf("got %v, want nil", err)
+	}
+
+	// test stale socket
+	stale := filepath.Join(dir, "stale")
+	l, err := net.Listen("unix", stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+	if err := removeStaleSocket(stale); err != nil {
+		t.Errorf("got %v, want nil", err)
+	}
+	if _, err := os.Lstat(stale); !os.IsNotExist(err) {
+		t.Errorf("stale socket not removed: %v", err)
+	}
+
+	// test socket in use
+	used := filepath.Join(dir, "used")
+	l, err = net.Listen("unix", used)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if err := removeStaleSocket(used); err == nil {
+		t.Error("socket in use not refused")
+	}
+	if _, err := os.Lstat(used); err != nil {
+		t.Errorf("socket in use removed: %v", err)
+	}
+
+	// test regular file
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := removeStaleSocket(file); err == nil {
+		t.Error("regular file not refused")
+	}
+	if _, err := os.Lstat(file); err != nil {
+		t.Errorf("regular file removed: %v", err)
+	}
+}
+
+// TestListenUnix tests listening on unix domain sockets
+func TestListenUnix(t *testing.T) {
+	dir := t.TempDir()
+	for _, mode := range []fs.FileMode{0600, 0660, 0666} {
+		path := filepath.Join(dir, "socket")
+		l, err := listenUnix(path, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// check mode and connect to socket
+		info, err := os.Lstat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Type() != fs.ModeSocket ||
+			info.Mode().Perm() != mode {
+			t.Errorf("got %v, want socket with mode %v",
+				info.Mode(), mode)
+		}
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			t.Errorf("%v: got %v, want nil", mode, err)
+		} else {
+			conn.Close()
+		}
+
+		// socket in use is refused
+		if _, err := listenUnix(path, mode); err == nil {
+			t.Errorf("%v: socket in use not refused", mode)
+		}
+
+		// closing removes socket and temporary directory
+		l.Close()
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("%v: got %d files after close, want 0", mode,
+				len(entries))
+		}
+	}
+}
@@ -2,38 +2,197 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html"
 	"io"
+	"io/fs"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/hwipl/schedule-events/internal/api"
+	"github.com/hwipl/schedule-events/internal/auth"
 	"github.com/hwipl/schedule-events/internal/command"
 	"github.com/hwipl/schedule-events/internal/event"
 )
 
 const (
 	// maxEventPostLength is the maximum content length of an
-	// event post request
-	maxEventPostLength = 512
+	// event post request; events with parameters, dependency lists,
+	// and hooks with names of up to 256 characters do not fit into
+	// 512 bytes
+	maxEventPostLength = 4096
+
+	// previewRuns is the default number of run times in a dry-run reply
+	previewRuns = 10
+
+	// maxPreviewRuns is the maximum number of run times in a dry-run
+	// reply
+	maxPreviewRuns = 1000
 )
 
 var (
 	// server is the http server
 	server *http.Server
+
+	// scheduled tracks all scheduled events
+	scheduled sync.WaitGroup
 )
 
+// writeError sends err to the client; api errors are sent as they are, event
+// field errors and other errors are sent as bad request errors
+func writeError(w http.ResponseWriter, err error) {
+	var e *api.Error
+	var f *event.FieldError
+	switch {
+	case errors.As(err, &e):
+	case errors.As(err, &f):
+		e = api.NewError(http.StatusBadRequest, api.CodeInvalidEvent,
+			"%s", f.Message)
+		e.Field = f.Field
+	default:
+		e = api.NewError(http.StatusBadRequest, api.CodeBadRequest,
+			"%v", err)
+	}
+	e.Write(w)
+}
+
 // internalError sends an internal server error to the client
 func internalError(w http.ResponseWriter) {
-	http.Error(w, "500 internal server error",
-		http.StatusInternalServerError)
+	api.NewError(http.StatusInternalServerError, api.CodeInternal,
+		"internal server error").Write(w)
+}
+
+// badRequest sends a bad request error with a formatted message to the
+// client
+func badRequest(w http.ResponseWriter, format string, a ...any) {
+	api.NewError(http.StatusBadRequest, api.CodeBadRequest, format,
+		a...).Write(w)
+}
+
+// unauthorized sends an unauthorized error to the client
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	api.NewError(http.StatusUnauthorized, api.CodeUnauthorized,
+		"missing or invalid api token").Write(w)
+}
+
+// forbidden sends a forbidden error with a formatted message to the client
+func forbidden(w http.ResponseWriter, format string, a ...any) {
+	api.NewError(http.StatusForbidden, api.CodeForbidden, format,
+		a...).Write(w)
+}
+
+// notFound sends a not found error with a formatted message to the client
+func notFound(w http.ResponseWriter, format string, a ...any) {
+	api.NewError(http.StatusNotFound, api.CodeNotFound, format,
+		a...).Write(w)
+}
+
+// conflict sends a conflict error with a formatted message to the client
+func conflict(w http.ResponseWriter, format string, a ...any) {
+	api.NewError(http.StatusConflict, api.CodeConflict, format,
+		a...).Write(w)
+}
+
+// methodNotAllowed sends a method not allowed error for request r with the
+// allowed methods to the client
+func methodNotAllowed(w http.ResponseWriter, r *http.Request,
+	allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	api.NewError(http.StatusMethodNotAllowed, api.CodeMethodNotAllowed,
+		"method %s not allowed on %s", r.Method, r.URL.Path).Write(w)
+}
+
+// identity returns the identity of the client of request r, i.e., the common
+// name of its tls client certificate if present and its remote address
+func identity(r *http.Request) string {
+	addr := r.RemoteAddr
+	if addr == "" || addr == "@" {
+		// clients on unix domain sockets have no address
+		addr = "unix socket"
+	}
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		return fmt.Sprintf("%s (%s)",
+			r.TLS.PeerCertificates[0].Subject.CommonName, addr)
+	}
+	return addr
+}
+
+// authenticate wraps handler and only passes requests with an api token
+// that allows the request if authentication is enabled; the token is added
+// to the request context; requests that change the server are logged with
+// the identity of the client
+func authenticate(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			log.Printf("Request %s %s from %s", r.Method,
+				r.URL.Path, identity(r))
+		}
+		if !auth.Enabled() {
+			handler(w, r)
+			return
+		}
+		t := auth.FromRequest(r)
+		if t == nil {
+			log.Println("invalid api token from:", identity(r))
+			unauthorized(w)
+			return
+		}
+		if !t.Allowed(r.Method, r.URL.Path) {
+			log.Printf("api token with role %s not allowed: %s %s "+
+				"from %s", t.Role, r.Method, r.URL.Path,
+				identity(r))
+			forbidden(w, "api token not allowed: %s %s",
+				r.Method, r.URL.Path)
+			return
+		}
+		handler(w, r.WithContext(auth.NewContext(r.Context(), t)))
+	}
 }
 
-// badRequest sends a bad request error to the client
-func badRequest(w http.ResponseWriter) {
-	http.Error(w, "400 bad request", http.StatusBadRequest)
+// hookEventAllowed returns whether api token t allows the command of the hook
+// event with name; hook events that do not exist are only allowed if t allows
+// all commands
+func hookEventAllowed(t *auth.Token, name string) bool {
+	evt := event.Get(name)
+	if evt == nil {
+		return t.AllCommandsAllowed()
+	}
+	return t.CommandAllowed(evt.Command)
+}
+
+// commandsAllowed returns whether the api token of request r allows the
+// commands of event evt including the commands of its hooks and hook events
+func commandsAllowed(r *http.Request, evt *event.Event) bool {
+	t := auth.FromContext(r.Context())
+	if t == nil {
+		return true
+	}
+	if !t.CommandAllowed(evt.Command) {
+		return false
+	}
+	for _, h := range []*event.Hook{evt.OnSuccess, evt.OnFailure,
+		evt.OnTimeout} {
+		switch {
+		case h == nil:
+		case h.Command != "" && !t.CommandAllowed(h.Command):
+			return false
+		case h.Event != "" && !hookEventAllowed(t, h.Event):
+			return false
+		}
+	}
+	return true
 }
 
 // handleCommandsGetAll handles a client "commands" GET request for all
@@ -53,7 +212,7 @@ func handleCommandsGetAll(w http.ResponseWriter, r *http.Request) {
 func handleCommandsGetOne(w http.ResponseWriter, r *http.Request, n string) {
 	cmd := command.Get(n)
 	if cmd == nil {
-		http.NotFound(w, r)
+		notFound(w, "command not found: %s", n)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -79,9 +238,19 @@ func handleCommands(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
 		handleCommandsGet(w, r)
+	default:
+		methodNotAllowed(w, r, http.MethodGet)
 	}
 }
 
+// parseEventsPath parses the url path of a client "events" request and
+// returns the event name and the optional sub resource
+func parseEventsPath(r *http.Request) (string, string) {
+	path := html.EscapeString(r.URL.Path)[len("/events/"):]
+	name, sub, _ := strings.Cut(path, "/")
+	return name, sub
+}
+
 // handleEventsGetAll handles a client "events" GET request for all events on
 // the server
 func handleEventsGetAll(w http.ResponseWriter, r *http.Request) {
@@ -99,7 +268,7 @@ func handleEventsGetAll(w http.ResponseWriter, r *http.Request) {
 func handleEventsGetOne(w http.ResponseWriter, r *http.Request, n string) {
 	evt := event.Get(n)
 	if evt == nil {
-		http.NotFound(w, r)
+		notFound(w, "event not found: %s", n)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -110,73 +279,328 @@ func handleEventsGetOne(w http.ResponseWriter, r *http.Request, n string) {
 	}
 }
 
+// handleEventsGetOutput handles a client "events" GET request for the output
+// of a command run of a specific event identified by its name n; the run is
+// identified by the optional "run" query parameter and defaults to the last
+// run
+func handleEventsGetOutput(w http.ResponseWriter, r *http.Request, n string) {
+	evt := event.Get(n)
+	if evt == nil {
+		notFound(w, "event not found: %s", n)
+		return
+	}
+	run := evt.LastRun()
+	if id := r.URL.Query().Get("run"); id != "" {
+		i, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			badRequest(w, "invalid run: %s", id)
+			return
+		}
+		run = evt.GetRun(i)
+	}
+	if run == nil {
+		notFound(w, "run not found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(run.Result)
+	if err != nil {
+		log.Println(err)
+		internalError(w)
+	}
+}
+
+// handleEventsGetRuns handles a client "events" GET request for the run
+// history of a specific event identified by its name n
+func handleEventsGetRuns(w http.ResponseWriter, r *http.Request, n string) {
+	evt := event.Get(n)
+	if evt == nil {
+		notFound(w, "event not found: %s", n)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(evt.Runs())
+	if err != nil {
+		log.Println(err)
+		internalError(w)
+	}
+}
+
 // handleEventsGet handles a client "events" GET request
 func handleEventsGet(w http.ResponseWriter, r *http.Request) {
-	name := html.EscapeString(r.URL.Path)[len("/events/"):]
-	if name == "" {
+	name, sub := parseEventsPath(r)
+	switch {
+	case name == "":
 		handleEventsGetAll(w, r)
+	case sub == "":
+		handleEventsGetOne(w, r, name)
+	case sub == "output":
+		handleEventsGetOutput(w, r, name)
+	case sub == "runs":
+		handleEventsGetRuns(w, r, name)
+	default:
+		notFound(w, "resource not found: %s", sub)
+	}
+}
+
+// handleEventsPostAction handles a client "events" POST request for action a
+// on a specific event identified by its name n
+func handleEventsPostAction(w http.ResponseWriter, r *http.Request, n,
+	a string) {
+	evt := event.Get(n)
+	if evt == nil {
+		notFound(w, "event not found: %s", n)
+		return
+	}
+	if !commandsAllowed(r, evt) {
+		log.Printf("event commands not allowed: %s from %s", evt.Name,
+			identity(r))
+		forbidden(w, "event commands not allowed: %s", evt.Name)
 		return
 	}
-	handleEventsGetOne(w, r, name)
+	switch a {
+	case "pause":
+		evt.Pause()
+	case "resume":
+		evt.Resume()
+	default:
+		notFound(w, "action not found: %s", a)
+	}
 }
 
-// handleEventsPost handles a client "events" POST request
-func handleEventsPost(w http.ResponseWriter, r *http.Request) {
-	// TODO: add more specific replies?
-	if r.Header.Get("Content-Type") != "application/json" {
-		log.Println("invalid content type")
-		badRequest(w)
+// readBody reads the json body of a client request
+func readBody(r *http.Request) ([]byte, error) {
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if ct != "application/json" {
+		return nil, api.NewError(http.StatusUnsupportedMediaType,
+			api.CodeUnsupportedMediaType,
+			"content type must be application/json")
+	}
+	tooLarge := api.NewError(http.StatusRequestEntityTooLarge,
+		api.CodeTooLarge, "request body larger than %d bytes",
+		maxEventPostLength)
+	if r.ContentLength > maxEventPostLength {
+		return nil, tooLarge
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxEventPostLength+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxEventPostLength {
+		return nil, tooLarge
+	}
+	if len(body) == 0 {
+		return nil, errors.New("empty request body")
+	}
+	return body, nil
+}
+
+// handleEventsPreview handles a client "events" POST request in dry-run
+// mode and replies with the next run times of event evt without scheduling it;
+// the number of run times is set with the optional "runs" query parameter
+func handleEventsPreview(w http.ResponseWriter, r *http.Request,
+	evt *event.Event) {
+	n := previewRuns
+	if runs := r.URL.Query().Get("runs"); runs != "" {
+		i, err := strconv.Atoi(runs)
+		if err != nil || i < 0 || i > maxPreviewRuns {
+			badRequest(w, "invalid runs: %s", runs)
+			return
+		}
+		n = i
+	}
+	times, err := evt.Preview(time.Now(), n)
+	if err != nil {
+		log.Println(err)
+		writeError(w, err)
 		return
 	}
-	if r.ContentLength <= 0 || r.ContentLength > maxEventPostLength {
-		log.Println("invalid content length")
-		badRequest(w)
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(times)
+	if err != nil {
+		log.Println(err)
+		internalError(w)
+	}
+}
+
+// handleEventsPost handles a client "events" POST request
+func handleEventsPost(w http.ResponseWriter, r *http.Request) {
+	if name, sub := parseEventsPath(r); sub != "" {
+		handleEventsPostAction(w, r, name, sub)
 		return
 	}
-	body, err := io.ReadAll(r.Body)
+	handleEventsAdd(w, r)
+}
+
+// handleEventsAdd handles a client "events" POST request that adds a new
+// event
+func handleEventsAdd(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody(r)
 	if err != nil {
 		log.Println(err)
-		badRequest(w)
+		writeError(w, err)
 		return
 	}
 	evt, err := event.NewFromJSON(body)
 	if err != nil {
 		log.Println(err)
-		badRequest(w)
+		writeError(w, err)
+		return
+	}
+	if err := evt.Check(); err != nil {
+		log.Println(err)
+		writeError(w, err)
+		return
+	}
+	if !commandsAllowed(r, evt) {
+		log.Printf("event commands not allowed: %s from %s", evt.Name,
+			identity(r))
+		forbidden(w, "event commands not allowed: %s", evt.Name)
 		return
 	}
 
-	// check if event is valid
-	if len(evt.Name) > 256 ||
-		len(evt.Command) > 256 ||
-		command.Get(evt.Command) == nil ||
-		!evt.StopDate.IsZero() && evt.StopDate.Before(evt.StartDate) ||
-		!evt.StopDate.IsZero() && evt.StopDate.Before(time.Now()) ||
-		evt.Timeout < 0 ||
-		evt.WaitMin < 0 ||
-		evt.WaitMax < 0 ||
-		evt.WaitMax != 0 && evt.WaitMax < evt.WaitMin ||
-		evt.Periodic && evt.WaitMin == 0 {
-
-		log.Println("invalid event")
-		badRequest(w)
+	// only preview run times of event in dry-run mode
+	if r.URL.Query().Get("dry-run") == "true" {
+		handleEventsPreview(w, r, evt)
 		return
 	}
 
 	// add and schedule event
 	log.Println("Adding new event:", evt.Name)
-	if event.Add(evt) {
-		go evt.Schedule()
+	if err := event.Add(evt); err != nil {
+		if errors.Is(err, event.ErrExists) {
+			log.Println("event already exists:", evt.Name)
+			conflict(w, "event already exists: %s", evt.Name)
+			return
+		}
+		log.Println(err)
+		writeError(w, err)
+		return
+	}
+	schedule(evt)
+	w.Header().Set("Location", strings.TrimSuffix(r.URL.Path, "/")+"/"+
+		url.PathEscape(evt.Name))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// handleEventsUpdate handles a client "events" PUT or PATCH request that
+// replaces or partially updates an existing event
+func handleEventsUpdate(w http.ResponseWriter, r *http.Request) {
+	// find event
+	name, sub := parseEventsPath(r)
+	switch {
+	case name == "":
+		methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+		return
+	case sub != "":
+		notFound(w, "resource not found: %s", sub)
+		return
 	}
+	handleEventsUpdateOne(w, r, name)
+}
+
+// handleEventsUpdateOne handles a client "events" PUT or PATCH request that
+// replaces or partially updates a specific event identified by its name
+func handleEventsUpdateOne(w http.ResponseWriter, r *http.Request,
+	name string) {
+	old := event.Get(name)
+	if old == nil {
+		notFound(w, "event not found: %s", name)
+		return
+	}
+	if !commandsAllowed(r, old) {
+		log.Printf("event commands not allowed: %s from %s", old.Name,
+			identity(r))
+		forbidden(w, "event commands not allowed: %s", old.Name)
+		return
+	}
+
+	// parse updated event
+	body, err := readBody(r)
+	if err != nil {
+		log.Println(err)
+		writeError(w, err)
+		return
+	}
+	var evt *event.Event
+	if r.Method == http.MethodPatch {
+		evt, err = old.Patch(body)
+	} else {
+		evt, err = event.NewFromJSON(body)
+	}
+	if err != nil {
+		log.Println(err)
+		writeError(w, err)
+		return
+	}
+	if evt.Name == "" {
+		evt.Name = name
+	}
+	if evt.Name != name {
+		log.Println("event name does not match:", evt.Name)
+		writeError(w, &event.FieldError{
+			Field:   "Name",
+			Message: "Name does not match event: " + name,
+		})
+		return
+	}
+	if err := evt.Check(); err != nil {
+		log.Println(err)
+		writeError(w, err)
+		return
+	}
+	if !commandsAllowed(r, evt) {
+		log.Printf("event commands not allowed: %s from %s", evt.Name,
+			identity(r))
+		forbidden(w, "event commands not allowed: %s", evt.Name)
+		return
+	}
+
+	// replace event, retire old event and schedule updated event; running
+	// commands of the old event are not stopped
+	log.Println("Updating event:", evt.Name)
+	if err := event.Replace(old, evt); err != nil {
+		if errors.Is(err, event.ErrNotFound) {
+			// removed or replaced in the meantime
+			notFound(w, "event not found: %s", name)
+			return
+		}
+		log.Println(err)
+		writeError(w, err)
+		return
+	}
+	old.Retire()
+	schedule(evt)
 }
 
 // handleEventsDelete handles a client "events" DELETE request
 func handleEventsDelete(w http.ResponseWriter, r *http.Request) {
 	// find event
-	name := html.EscapeString(r.URL.Path)[len("/events/"):]
+	name, sub := parseEventsPath(r)
+	switch {
+	case name == "":
+		methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+		return
+	case sub != "":
+		notFound(w, "resource not found: %s", sub)
+		return
+	}
+	handleEventsDeleteOne(w, r, name)
+}
+
+// handleEventsDeleteOne handles a client "events" DELETE request for a
+// specific event identified by its name
+func handleEventsDeleteOne(w http.ResponseWriter, r *http.Request,
+	name string) {
 	e := event.Get(name)
 	if e == nil {
-		http.NotFound(w, r)
+		notFound(w, "event not found: %s", name)
+		return
+	}
+	if !commandsAllowed(r, e) {
+		log.Printf("event commands not allowed: %s from %s", e.Name,
+			identity(r))
+		forbidden(w, "event commands not allowed: %s", e.Name)
 		return
 	}
 
@@ -195,14 +619,21 @@ func handleEvents(w http.ResponseWriter, r *http.Request) {
 		handleEventsGet(w, r)
 	case http.MethodPost:
 		handleEventsPost(w, r)
+	case http.MethodPut, http.MethodPatch:
+		handleEventsUpdate(w, r)
 	case http.MethodDelete:
 		handleEventsDelete(w, r)
+	default:
+		methodNotAllowed(w, r, http.MethodGet, http.MethodPost,
+			http.MethodPut, http.MethodPatch, http.MethodDelete)
 	}
 }
 
 // handleStatusGet handles a client "status" GET request
 func handleStatusGet(w http.ResponseWriter, r *http.Request) {
-	_, err := fmt.Fprint(w, "Status: OK\n")
+	queued, running := event.Queue()
+	_, err := fmt.Fprintf(w, "Status: OK\nQueued runs: %d\n"+
+		"Running runs: %d\n", queued, running)
 	if err != nil {
 		log.Println(err)
 		internalError(w)
@@ -216,6 +647,8 @@ func handleStatusPost(w http.ResponseWriter, r *http.Request) {
 		Shutdown()
 	case "/status/stop":
 		Stop()
+	default:
+		notFound(w, "status not found: %s", r.URL.Path)
 	}
 }
 
@@ -226,9 +659,17 @@ func handleStatus(w http.ResponseWriter, r *http.Request) {
 		handleStatusGet(w, r)
 	case http.MethodPost:
 		handleStatusPost(w, r)
+	default:
+		methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
 	}
 }
 
+// schedule schedules event e and tracks it until it is done
+func schedule(e *event.Event) {
+	scheduled.Add(1)
+	e.Start(scheduled.Done)
+}
+
 // Shutdown shuts the server down
 func Shutdown() {
 	err := server.Shutdown(context.Background())
@@ -244,24 +685,117 @@ func Stop() {
 	}
 }
 
-// Run starts the server listening on addr
-func Run(addr string) {
-	log.Println("Starting server listening on:", addr)
+// Config is the configuration of the server
+type Config struct {
+	// Address is the address the server listens on
+	Address string
+
+	// StateFile is the file the event list is persisted in; empty
+	// disables persistence
+	StateFile string
+
+	// TokensFile is the file with the api tokens of clients; empty
+	// disables authentication
+	TokensFile string
+
+	// TLSCert and TLSKey are the files with the tls certificate and key
+	// of the server; empty disables tls
+	TLSCert string
+	TLSKey  string
+
+	// TLSClientCA is the file with the ca certificates for verifying
+	// client certificates; empty disables client certificates
+	TLSClientCA string
+
+	// SocketMode is the file permissions of the unix domain socket if
+	// Address is a unix domain socket address
+	SocketMode fs.FileMode
+}
+
+// tlsConfig returns the tls configuration of the server with config
+func tlsConfig(config *Config) (*tls.Config, error) {
+	if config.TLSCert == "" || config.TLSKey == "" {
+		return nil, errors.New("tls certificate or key missing")
+	}
+	c := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.TLSClientCA == "" {
+		return c, nil
+	}
+
+	// require and verify client certificates
+	pem, err := os.ReadFile(config.TLSClientCA)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no ca certificates in %s",
+			config.TLSClientCA)
+	}
+	c.ClientCAs = pool
+	c.ClientAuth = tls.RequireAndVerifyClientCert
+	return c, nil
+}
+
+// Run starts the server with config
+func Run(config *Config) {
+	log.Println("Starting server listening on:", config.Address)
+
+	// restore events from state file and persist future changes
+	if config.StateFile != "" {
+		if err := event.LoadState(config.StateFile); err != nil {
+			log.Fatal(err)
+		}
+		if err := event.Persist(config.StateFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// load api tokens and enable authentication
+	if config.TokensFile != "" {
+		if err := auth.TokensFromJSON(config.TokensFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// check tls configuration
+	var tlsConf *tls.Config
+	if config.TLSCert != "" || config.TLSKey != "" ||
+		config.TLSClientCA != "" {
+		c, err := tlsConfig(config)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tlsConf = c
+	}
 
 	// schedule all events
 	for _, e := range event.List() {
-		go e.Schedule()
+		schedule(e)
 	}
 
-	// start http server
-	http.HandleFunc("/commands/", handleCommands)
-	http.HandleFunc("/events/", handleEvents)
-	http.HandleFunc("/status/", handleStatus)
+	// start http server with legacy and api v1 routes
+	http.HandleFunc("/commands/", authenticate(handleCommands))
+	http.HandleFunc("/events/", authenticate(handleEvents))
+	http.HandleFunc("/status/", authenticate(handleStatus))
+	handleV1(http.DefaultServeMux)
 
-	server = &http.Server{Addr: addr}
-	log.Println(server.ListenAndServe())
+	server = &http.Server{Addr: config.Address, TLSConfig: tlsConf}
+	l, err := listen(config.Address, config.SocketMode)
+	switch {
+	case err != nil:
+		log.Println(err)
+	case tlsConf != nil:
+		log.Println(server.ServeTLS(l, config.TLSCert, config.TLSKey))
+	default:
+		log.Println(server.Serve(l))
+	}
 
-	// server stopped, stop all events
+	// server stopped, stop persisting the event list, stop all events and
+	// wait until they are done
+	if err := event.Persist(""); err != nil {
+		log.Println(err)
+	}
 	Stop()
-	time.Sleep(1 * time.Second) // TODO: improve
+	scheduled.Wait()
 }
@@ -0,0 +1,179 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hwipl/schedule-events/internal/auth"
+	"github.com/hwipl/schedule-events/internal/command"
+	"github.com/hwipl/schedule-events/internal/event"
+)
+
+// TestCommandsAllowed tests checking the commands of events and their hooks
+// with api tokens
+func TestCommandsAllowed(t *testing.T) {
+	defer event.Flush()
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	err := os.WriteFile(path, []byte(`[
+		{"Token":"schedule-token","Role":"schedule","Commands":["ls"]}
+	]`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.TokensFromJSON(path); err != nil {
+		t.Fatal(err)
+	}
+	command.Add(&command.Command{Name: "ls", Executable: "ls"})
+	command.Add(&command.Command{Name: "rm", Executable: "rm"})
+	event.Add(&event.Event{Name: "allowed-event", Command: "ls"})
+	event.Add(&event.Event{Name: "forbidden-event", Command: "rm"})
+
+	for _, test := range []struct {
+		body string
+		want int
+	}{
+		{`{"Name":"e","Command":"ls"}`, http.StatusOK},
+		{`{"Name":"e","Command":"rm"}`, http.StatusForbidden},
+		{`{"Name":"e","Command":"ls","OnSuccess":{"Command":"rm"}}`,
+			http.StatusForbidden},
+		{`{"Name":"e","Command":"ls","OnSuccess":` +
+			`{"Event":"allowed-event"}}`, http.StatusOK},
+		{`{"Name":"e","Command":"ls","OnFailure":` +
+			`{"Event":"forbidden-event"}}`, http.StatusForbidden},
+		{`{"Name":"e","Command":"ls","OnTimeout":` +
+			`{"Event":"unknown-event"}}`, http.StatusForbidden},
+	} {
+		// only preview events, so allowed events are not scheduled
+		r := httptest.NewRequest(http.MethodPost,
+			"/api/v1/events?dry-run=true", strings.NewReader(test.body))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Authorization", "Bearer schedule-token")
+		w := httptest.NewRecorder()
+		authenticate(handleV1Events)(w, r)
+		if w.Code != test.want {
+			t.Errorf("%s: got %d, want %d", test.body, w.Code,
+				test.want)
+		}
+	}
+}
+
+// writeCertificates writes a ca certificate and a certificate and key signed
+// by it to dir and returns their file names
+func writeCertificates(t *testing.T, dir string) (ca, cert, key string) {
+	t.Helper()
+	newKey := func() *ecdsa.PrivateKey {
+		k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	writePEM := func(name, typ string, b []byte) string {
+		path := filepath.Join(dir, name)
+		data := pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: b})
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// create ca certificate
+	caKey := newKey()
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate,
+		caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// create certificate signed by ca
+	certKey := newKey()
+	certTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "test-cert"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth,
+			x509.ExtKeyUsageClientAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, certTemplate,
+		caTemplate, &certKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(certKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return writePEM("ca.pem", "CERTIFICATE", caDER),
+		writePEM("cert.pem", "CERTIFICATE", certDER),
+		writePEM("key.pem", "PRIVATE KEY", keyDER)
+}
+
+// TestTLSConfig tests creating the tls configuration of the server
+func TestTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca, cert, key := writeCertificates(t, dir)
+	empty := filepath.Join(dir, "empty.pem")
+	if err := os.WriteFile(empty, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// test invalid configurations
+	for _, config := range []*Config{
+		{TLSKey: key},
+		{TLSCert: cert},
+		{TLSCert: cert, TLSKey: key, TLSClientCA: empty},
+		{TLSCert: cert, TLSKey: key,
+			TLSClientCA: filepath.Join(dir, "does-not-exist.pem")},
+	} {
+		if _, err := tlsConfig(config); err == nil {
+			t.Errorf("%+v: got nil, want error", config)
+		}
+	}
+
+	// test without client certificates
+	c, err := tlsConfig(&Config{TLSCert: cert, TLSKey: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ClientAuth != tls.NoClientCert || c.ClientCAs != nil {
+		t.Errorf("got %v, want no client certificates", c.ClientAuth)
+	}
+
+	// test with client certificates
+	c, err = tlsConfig(&Config{TLSCert: cert, TLSKey: key,
+		TLSClientCA: ca})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ClientAuth != tls.RequireAndVerifyClientCert ||
+		c.ClientCAs == nil {
+		t.Errorf("got %v, want %v", c.ClientAuth,
+			tls.RequireAndVerifyClientCert)
+	}
+}
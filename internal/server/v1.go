@@ -0,0 +1,186 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/hwipl/schedule-events/internal/api"
+	"github.com/hwipl/schedule-events/internal/event"
+)
+
+// handleV1Commands handles a client request on the commands of api v1
+func handleV1Commands(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handleCommandsGetAll(w, r)
+	default:
+		methodNotAllowed(w, r, http.MethodGet)
+	}
+}
+
+// handleV1Command handles a client request on a specific command of api v1
+func handleV1Command(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handleCommandsGetOne(w, r, r.PathValue("name"))
+	default:
+		methodNotAllowed(w, r, http.MethodGet)
+	}
+}
+
+// handleV1Events handles a client request on the events of api v1; deleting
+// the events stops all events
+func handleV1Events(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handleEventsGetAll(w, r)
+	case http.MethodPost:
+		handleEventsAdd(w, r)
+	case http.MethodDelete:
+		Stop()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, r, http.MethodGet, http.MethodPost,
+			http.MethodDelete)
+	}
+}
+
+// handleV1Event handles a client request on a specific event of api v1
+func handleV1Event(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	switch r.Method {
+	case http.MethodGet:
+		handleEventsGetOne(w, r, name)
+	case http.MethodPut, http.MethodPatch:
+		handleEventsUpdateOne(w, r, name)
+	case http.MethodDelete:
+		handleEventsDeleteOne(w, r, name)
+	default:
+		methodNotAllowed(w, r, http.MethodGet, http.MethodPut,
+			http.MethodPatch, http.MethodDelete)
+	}
+}
+
+// handleV1EventRuns handles a client request on the run history of a
+// specific event of api v1
+func handleV1EventRuns(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handleEventsGetRuns(w, r, r.PathValue("name"))
+	default:
+		methodNotAllowed(w, r, http.MethodGet)
+	}
+}
+
+// handleV1EventOutput handles a client request on the output of a specific
+// event of api v1
+func handleV1EventOutput(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handleEventsGetOutput(w, r, r.PathValue("name"))
+	default:
+		methodNotAllowed(w, r, http.MethodGet)
+	}
+}
+
+// handleV1EventAction returns the handler of a client request for action,
+// i.e., pause or resume, on a specific event of api v1
+func handleV1EventAction(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			handleEventsPostAction(w, r, r.PathValue("name"),
+				action)
+		default:
+			methodNotAllowed(w, r, http.MethodPost)
+		}
+	}
+}
+
+// handleV1StatusGet handles a client GET request on the status of api v1
+func handleV1StatusGet(w http.ResponseWriter, r *http.Request) {
+	queued, running := event.Queue()
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(&api.Status{
+		Status:      api.StatusOK,
+		QueuedRuns:  queued,
+		RunningRuns: running,
+	})
+	if err != nil {
+		log.Println(err)
+		internalError(w)
+	}
+}
+
+// handleV1StatusPut handles a client PUT request on the status of api v1;
+// setting the status to shutdown shuts the server down after the reply
+func handleV1StatusPut(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody(r)
+	if err != nil {
+		log.Println(err)
+		writeError(w, err)
+		return
+	}
+	status := &api.Status{}
+	if err := json.Unmarshal(body, status); err != nil {
+		log.Println(err)
+		writeError(w, err)
+		return
+	}
+	if status.Status != api.StatusShutdown {
+		badRequest(w, "invalid status: %s", status.Status)
+		return
+	}
+	go Shutdown()
+}
+
+// handleV1Status handles a client request on the status of api v1
+func handleV1Status(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		handleV1StatusGet(w, r)
+	case http.MethodPut:
+		handleV1StatusPut(w, r)
+	default:
+		methodNotAllowed(w, r, http.MethodGet, http.MethodPut)
+	}
+}
+
+// handleV1OpenAPI handles a client request on the OpenAPI document of api v1
+func handleV1OpenAPI(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(api.OpenAPI); err != nil {
+			log.Println(err)
+		}
+	default:
+		methodNotAllowed(w, r, http.MethodGet)
+	}
+}
+
+// handleV1NotFound handles a client request on an unknown resource of api v1
+func handleV1NotFound(w http.ResponseWriter, r *http.Request) {
+	notFound(w, "resource not found: %s", r.URL.Path)
+}
+
+// handleV1 adds the handlers of api v1 to mux; all handlers are wrapped with
+// authentication, unknown resources are not found
+func handleV1(mux *http.ServeMux) {
+	for pattern, handler := range map[string]http.HandlerFunc{
+		"/":                     handleV1NotFound,
+		"/commands":             handleV1Commands,
+		"/commands/{name}":      handleV1Command,
+		"/events":               handleV1Events,
+		"/events/{name}":        handleV1Event,
+		"/events/{name}/runs":   handleV1EventRuns,
+		"/events/{name}/output": handleV1EventOutput,
+		"/events/{name}/pause":  handleV1EventAction("pause"),
+		"/events/{name}/resume": handleV1EventAction("resume"),
+		"/status":               handleV1Status,
+		"/openapi.json":         handleV1OpenAPI,
+	} {
+		mux.HandleFunc(api.Prefix+pattern, authenticate(handler))
+	}
+}
@@ -0,0 +1,265 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hwipl/schedule-events/internal/api"
+	"github.com/hwipl/schedule-events/internal/auth"
+	"github.com/hwipl/schedule-events/internal/command"
+	"github.com/hwipl/schedule-events/internal/event"
+)
+
+// loadTestTokens loads the api tokens used in the tests: an admin token and
+// a schedule token that only allows the command "test-allowed"
+func loadTestTokens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	err := os.WriteFile(path, []byte(`[
+		{"Token":"admin-token","Role":"admin"},
+		{"Token":"schedule-token","Role":"schedule",
+			"Commands":["test-allowed"]}
+	]`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.TokensFromJSON(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// stopTestEvents stops all events scheduled in the tests and waits until
+// they are done
+func stopTestEvents() {
+	Stop()
+	scheduled.Wait()
+}
+
+// checkError checks that the reply in w is an api error with status, code
+// and field
+func checkError(t *testing.T, w *httptest.ResponseRecorder, status int,
+	code, field string) {
+	t.Helper()
+	var e api.Error
+	if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil {
+		t.Errorf("got %q, want api error: %v", w.Body, err)
+		return
+	}
+	if e.Status != status || e.Code != code || e.Field != field {
+		t.Errorf("got %d %s %q, want %d %s %q", e.Status, e.Code,
+			e.Field, status, code, field)
+	}
+}
+
+// TestV1Routes tests the routes of api v1
+func TestV1Routes(t *testing.T) {
+	loadTestTokens(t)
+	mux := http.NewServeMux()
+	handleV1(mux)
+
+	for _, test := range []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{http.MethodGet, "/api/v1/unknown", http.StatusNotFound},
+		{http.MethodGet, "/api/v1/events/x/unknown",
+			http.StatusNotFound},
+		{http.MethodPost, "/api/v1/events/x/unknown",
+			http.StatusNotFound},
+		{http.MethodGet, "/api/v1/events/x/pause",
+			http.StatusMethodNotAllowed},
+		{http.MethodPost, "/api/v1/events/x/resume",
+			http.StatusNotFound},
+		{http.MethodDelete, "/api/v1/events", http.StatusNoContent},
+	} {
+		r := httptest.NewRequest(test.method, test.path, nil)
+		r.Header.Set("Authorization", "Bearer admin-token")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != test.want {
+			t.Errorf("%s %s: got %d, want %d", test.method,
+				test.path, w.Code, test.want)
+		}
+	}
+}
+
+// TestV1EventsAdd tests adding events with api v1
+func TestV1EventsAdd(t *testing.T) {
+	defer stopTestEvents()
+	loadTestTokens(t)
+	command.Add(&command.Command{Name: "test-allowed", Executable: "true"})
+	mux := http.NewServeMux()
+	handleV1(mux)
+
+	// events are periodic with a long wait, so they are not finished
+	// and removed while testing
+	newEvent := func(name, fields string) string {
+		return `{"Name":"` + name + `","Command":"test-allowed",` +
+			`"Periodic":true,"WaitMin":3600000000000` + fields + `}`
+	}
+	for _, test := range []struct {
+		method string
+		ctype  string
+		body   string
+		want   int
+		code   string
+		field  string
+		header string
+		value  string
+	}{
+		// added event
+		{http.MethodPost, "application/json", newEvent("add 1", ""),
+			http.StatusCreated, "", "",
+			"Location", "/api/v1/events/add%201"},
+		{http.MethodPost, "application/json; charset=utf-8",
+			newEvent("add2", ""), http.StatusCreated, "", "",
+			"Location", "/api/v1/events/add2"},
+
+		// invalid requests
+		{http.MethodPost, "application/json", newEvent("add 1", ""),
+			http.StatusConflict, api.CodeConflict, "", "", ""},
+		{http.MethodPost, "application/json",
+			newEvent(strings.Repeat("x", maxEventPostLength), ""),
+			http.StatusRequestEntityTooLarge, api.CodeTooLarge, "",
+			"", ""},
+		{http.MethodPost, "text/plain", newEvent("add3", ""),
+			http.StatusUnsupportedMediaType,
+			api.CodeUnsupportedMediaType, "", "", ""},
+		{http.MethodPost, "application/json", "",
+			http.StatusBadRequest, api.CodeBadRequest, "", "", ""},
+		{http.MethodPost, "application/json", "{",
+			http.StatusBadRequest, api.CodeBadRequest, "", "", ""},
+		{http.MethodPut, "application/json", newEvent("add3", ""),
+			http.StatusMethodNotAllowed, api.CodeMethodNotAllowed,
+			"", "Allow", "GET, POST, DELETE"},
+
+		// invalid event
+		{http.MethodPost, "application/json",
+			newEvent("add3", `,"WaitMax":1`), http.StatusBadRequest,
+			api.CodeInvalidEvent, "WaitMax", "", ""},
+	} {
+		r := httptest.NewRequest(test.method, "/api/v1/events",
+			strings.NewReader(test.body))
+		r.Header.Set("Content-Type", test.ctype)
+		r.Header.Set("Authorization", "Bearer admin-token")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != test.want {
+			t.Errorf("%s %.40s: got %d, want %d", test.method,
+				test.body, w.Code, test.want)
+		}
+		if test.header != "" && w.Header().Get(test.header) !=
+			test.value {
+			t.Errorf("%s %.40s: got %s %q, want %q", test.method,
+				test.body, test.header,
+				w.Header().Get(test.header), test.value)
+		}
+		if test.code != "" {
+			checkError(t, w, test.want, test.code, test.field)
+		}
+	}
+}
+
+// TestV1EventsUpdate tests replacing and patching events with api v1
+func TestV1EventsUpdate(t *testing.T) {
+	defer stopTestEvents()
+	loadTestTokens(t)
+	command.Add(&command.Command{Name: "test-allowed", Executable: "true"})
+	command.Add(&command.Command{Name: "test-forbidden",
+		Executable: "true"})
+	mux := http.NewServeMux()
+	handleV1(mux)
+	request := func(method, path, token, body string) (
+		w *httptest.ResponseRecorder) {
+		r := httptest.NewRequest(method, path,
+			strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Authorization", "Bearer "+token)
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	// add periodic events, they run once right after adding them
+	for _, body := range []string{
+		`{"Name":"update1","Command":"test-allowed","Periodic":true,` +
+			`"WaitMin":3600000000000}`,
+		`{"Name":"update2","Command":"test-forbidden",` +
+			`"Periodic":true,"WaitMin":3600000000000}`,
+	} {
+		w := request(http.MethodPost, "/api/v1/events", "admin-token",
+			body)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("%s: got %d, want %d", body, w.Code,
+				http.StatusCreated)
+		}
+	}
+
+	// test invalid updates
+	for _, test := range []struct {
+		method string
+		path   string
+		token  string
+		body   string
+		want   int
+		code   string
+		field  string
+	}{
+		{http.MethodPut, "/api/v1/events/unknown", "admin-token",
+			`{"Command":"test-allowed"}`, http.StatusNotFound,
+			api.CodeNotFound, ""},
+		{http.MethodPatch, "/api/v1/events/unknown", "admin-token",
+			`{"WaitMin":1}`, http.StatusNotFound,
+			api.CodeNotFound, ""},
+		{http.MethodPut, "/api/v1/events/update1", "admin-token",
+			`{"Name":"other","Command":"test-allowed"}`,
+			http.StatusBadRequest, api.CodeInvalidEvent, "Name"},
+		{http.MethodPatch, "/api/v1/events/update1", "admin-token",
+			`{"Name":"other"}`, http.StatusBadRequest,
+			api.CodeInvalidEvent, "Name"},
+		{http.MethodPatch, "/api/v1/events/update1", "schedule-token",
+			`{"Command":"test-forbidden"}`, http.StatusForbidden,
+			api.CodeForbidden, ""},
+		{http.MethodPut, "/api/v1/events/update2", "schedule-token",
+			`{"Command":"test-allowed"}`, http.StatusForbidden,
+			api.CodeForbidden, ""},
+	} {
+		w := request(test.method, test.path, test.token, test.body)
+		if w.Code != test.want {
+			t.Errorf("%s %s %s: got %d, want %d", test.method,
+				test.path, test.body, w.Code, test.want)
+		}
+		checkError(t, w, test.want, test.code, test.field)
+	}
+
+	// wait for the first run of the periodic event
+	deadline := time.Now().Add(5 * time.Second)
+	for len(event.Get("update1").Runs()) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("event did not run")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// test patch that keeps the run history
+	old := event.Get("update1")
+	w := request(http.MethodPatch, "/api/v1/events/update1",
+		"schedule-token", `{"WaitMin":7200000000000}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got %d, want %d", w.Code, http.StatusOK)
+	}
+	evt := event.Get("update1")
+	if evt == old || evt.WaitMin != 2*time.Hour ||
+		evt.Command != "test-allowed" || !evt.Periodic {
+		t.Errorf("got %+v, want patched event", evt)
+	}
+	if len(evt.Runs()) != 1 || evt.Runs()[0] != old.Runs()[0] {
+		t.Errorf("got %v, want run history of old event", evt.Runs())
+	}
+}